@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ingestRejectedTotal *prometheus.CounterVec
+
+// Reasons for rejecting a log request, used as the "reason" label
+const (
+	rejectMissingCredentials = "missing_credentials"
+	rejectInvalidCredentials = "invalid_credentials"
+	rejectInvalidSignature   = "invalid_signature"
+	rejectMissingClientCert  = "missing_client_certificate"
+)
+
+func authenticateIngest(next http.Handler) http.Handler {
+	switch config.Ingest.Auth.Mode {
+	case "bearer":
+		return bearerAuth(next)
+	case "basic":
+		return basicAuth(next)
+	case "hmac":
+		return hmacAuth(next)
+	default:
+		return next
+	}
+}
+
+func bearerAuth(next http.Handler) http.Handler {
+	expected := []byte(config.Ingest.Auth.Token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			rejectIngest(w, rejectMissingCredentials)
+
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), expected) != 1 {
+			rejectIngest(w, rejectInvalidCredentials)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func basicAuth(next http.Handler) http.Handler {
+	// Index the bcrypt password hashes by username
+	users := map[string][]byte{}
+	for _, user := range config.Ingest.Auth.Users {
+		users[user.Username] = []byte(user.PasswordHash)
+	}
+
+	// Unknown usernames are compared against a dummy hash of the same cost,
+	// so response times don't reveal which usernames exist
+	cost := bcrypt.DefaultCost
+	for _, hashed := range users {
+		if hashedCost, err := bcrypt.Cost(hashed); err == nil {
+			cost = hashedCost
+
+			break
+		}
+	}
+
+	dummy, err := bcrypt.GenerateFromPassword([]byte("dummy"), cost)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to generate dummy password hash")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="logs"`)
+			rejectIngest(w, rejectMissingCredentials)
+
+			return
+		}
+
+		hashed, ok := users[username]
+		if !ok {
+			hashed = dummy
+		}
+
+		if bcrypt.CompareHashAndPassword(hashed, []byte(password)) != nil || !ok {
+			rejectIngest(w, rejectInvalidCredentials)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hmacAuth(next http.Handler) http.Handler {
+	secret := []byte(config.Ingest.Auth.Secret)
+	header := config.Ingest.Auth.Header
+	algorithm := config.Ingest.Auth.Algorithm
+
+	var newHash func() hash.Hash
+	switch algorithm {
+	case "sha512":
+		newHash = sha512.New
+	default:
+		newHash = sha256.New
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The signature may be prefixed with the algorithm, e.g. "sha256=<hex>"
+		signatureHex := strings.TrimPrefix(r.Header.Get(header), algorithm+"=")
+		if signatureHex == "" {
+			rejectIngest(w, rejectMissingCredentials)
+
+			return
+		}
+
+		signature, err := hex.DecodeString(signatureHex)
+		if err != nil {
+			rejectIngest(w, rejectInvalidSignature)
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		mac := hmac.New(newHash, secret)
+		mac.Write(body)
+
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			rejectIngest(w, rejectInvalidSignature)
+
+			return
+		}
+
+		// Restore the body for the next handler
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func rejectIngest(w http.ResponseWriter, reason string) {
+	logrus.WithField("reason", reason).Debug("Rejected log")
+
+	ingestRejectedTotal.With(prometheus.Labels{"reason": reason}).Inc()
+
+	w.WriteHeader(http.StatusUnauthorized)
+}
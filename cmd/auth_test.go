@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// echoHandler answers with the request body
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+})
+
+func serveIngest(handler http.Handler, setup func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/logs", strings.NewReader(`{"request":{}}`))
+	setup(r)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	return w
+}
+
+func rejected(reason string) float64 {
+	return testutil.ToFloat64(ingestRejectedTotal.With(prometheus.Labels{"reason": reason}))
+}
+
+func TestBearerAuth(t *testing.T) {
+	setupTestConfig(t, `
+ingest:
+  auth:
+    mode: bearer
+    token: secret
+`)
+
+	handler := authenticateIngest(echoHandler)
+
+	w := serveIngest(handler, func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer secret")
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveIngest(handler, func(r *http.Request) {})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 1.0, rejected(rejectMissingCredentials))
+
+	w = serveIngest(handler, func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer wrong")
+	})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 1.0, rejected(rejectInvalidCredentials))
+}
+
+func TestBasicAuth(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	setupTestConfig(t, `
+ingest:
+  auth:
+    mode: basic
+    users:
+      - username: kong
+        password_hash: `+string(hashed)+`
+`)
+
+	handler := authenticateIngest(echoHandler)
+
+	w := serveIngest(handler, func(r *http.Request) {
+		r.SetBasicAuth("kong", "secret")
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveIngest(handler, func(r *http.Request) {})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="logs"`, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, 1.0, rejected(rejectMissingCredentials))
+
+	w = serveIngest(handler, func(r *http.Request) {
+		r.SetBasicAuth("kong", "wrong")
+	})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serveIngest(handler, func(r *http.Request) {
+		r.SetBasicAuth("unknown", "secret")
+	})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 2.0, rejected(rejectInvalidCredentials))
+}
+
+func TestHMACAuth(t *testing.T) {
+	setupTestConfig(t, `
+ingest:
+  auth:
+    mode: hmac
+    secret: secret
+`)
+
+	handler := authenticateIngest(echoHandler)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`{"request":{}}`))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	for _, value := range []string{signature, "sha256=" + signature} {
+		w := serveIngest(handler, func(r *http.Request) {
+			r.Header.Set("X-Signature", value)
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The verified body is passed on to the next handler
+		assert.Equal(t, `{"request":{}}`, w.Body.String())
+	}
+
+	w := serveIngest(handler, func(r *http.Request) {})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 1.0, rejected(rejectMissingCredentials))
+
+	w = serveIngest(handler, func(r *http.Request) {
+		r.Header.Set("X-Signature", "not hex")
+	})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = serveIngest(handler, func(r *http.Request) {
+		r.Header.Set("X-Signature", hex.EncodeToString([]byte("wrong")))
+	})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 2.0, rejected(rejectInvalidSignature))
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"api-usage/pkg/swagger"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/sirupsen/logrus"
+)
+
+// staleError is returned by a load that failed, together with the last known
+// good specification read from the cache
+type staleError struct {
+	err error
+}
+
+func (e *staleError) Error() string {
+	return e.err.Error()
+}
+
+func (e *staleError) Unwrap() error {
+	return e.err
+}
+
+// cachePath returns the path a specification of the given URL is cached at.
+// The documents its references were resolved from are cached next to it, with
+// a ".refs" extension.
+func cachePath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+
+	return filepath.Join(config.OpenAPI.Cache.Dir, hex.EncodeToString(sum[:8])+".spec")
+}
+
+// writeCache writes the document of a loaded specification and the documents
+// of its references to the cache, replacing the previous copies atomically
+func writeCache(url string, spec *swagger.Specification) error {
+	if err := os.MkdirAll(config.OpenAPI.Cache.Dir, 0o755); err != nil {
+		return err
+	}
+
+	// Documents are cached as strings, keeping the JSON readable
+	refDocuments := map[string]string{}
+	for ref, document := range spec.RefDocuments {
+		refDocuments[ref] = string(document)
+	}
+
+	refsBytes, err := jsoniter.Marshal(refDocuments)
+	if err != nil {
+		return err
+	}
+
+	// The references are written first, so a cached document never lacks
+	// them
+	if err := writeFileAtomic(cachePath(url)+".refs", refsBytes); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(cachePath(url), spec.Raw)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".spec-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadURLWithCache loads the specification of the source from the URL,
+// writing it to the cache on success. When loading fails, the cached copy is
+// returned together with a staleError.
+func loadURLWithCache(ctx context.Context, source string, url string) (*swagger.Specification, error) {
+	spec, err := loadSpecificationURL(ctx, source, url)
+	if config.OpenAPI.Cache.Dir == "" {
+		return spec, err
+	}
+
+	if err == nil {
+		if err := writeCache(url, spec); err != nil {
+			logrus.WithError(err).WithField("url", url).Warn("Failed to cache OpenAPI specification")
+		}
+
+		return spec, nil
+	}
+
+	if errors.Is(err, swagger.ErrNotModified) {
+		return nil, err
+	}
+
+	cached, cacheErr := readCache(ctx, source, url)
+	if cacheErr != nil {
+		logrus.WithError(cacheErr).WithField("url", url).Debug("No cached OpenAPI specification")
+
+		return nil, err
+	}
+
+	return cached, &staleError{err: err}
+}
+
+func readCache(ctx context.Context, source string, url string) (*swagger.Specification, error) {
+	specBytes, err := os.ReadFile(cachePath(url))
+	if err != nil {
+		return nil, err
+	}
+
+	// Copies cached before references were cached have none, so they only
+	// load when they don't reference other documents
+	refDocuments := map[string][]byte{}
+
+	refsBytes, err := os.ReadFile(cachePath(url) + ".refs")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		var cached map[string]string
+		if err := jsoniter.Unmarshal(refsBytes, &cached); err != nil {
+			return nil, fmt.Errorf("cached references: %w", err)
+		}
+
+		for ref, document := range cached {
+			refDocuments[ref] = []byte(document)
+		}
+	}
+
+	loader, err := urlLoader(source, url)
+	if err != nil {
+		return nil, err
+	}
+
+	// References are resolved from the cache only, as their origin is most
+	// likely unreachable too
+	spec, err := loader.ParseCached(ctx, specBytes, refDocuments)
+	if err != nil {
+		return nil, fmt.Errorf("cached copy: %w", err)
+	}
+
+	return spec, nil
+}
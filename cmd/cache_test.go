@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+func staleValue(api string) float64 {
+	return testutil.ToFloat64(specStale.With(prometheus.Labels{"api": api}))
+}
+
+func TestLoadURLWithCache(t *testing.T) {
+	ctx := context.Background()
+
+	handler := http.FileServer(http.Dir("../testdata/multi"))
+
+	server := httptest.NewServer(handler)
+	addr := server.Listener.Addr().String()
+	specURL := server.URL + "/openapi.yaml"
+
+	yaml := "openapi: {url: " + specURL + ", retries: 0, cache: {dir: " + t.TempDir() + "}}"
+	title := "Multi-file OpenAPI 3.0"
+
+	setupTestConfig(t, yaml)
+
+	// Loaded specifications are cached with their references
+	assert.NoError(t, loadSpecification(ctx))
+	assert.False(t, apis[specURL].Stale)
+	assert.Equal(t, 0.0, staleValue(title))
+
+	_, err := os.Stat(cachePath(specURL))
+	assert.NoError(t, err)
+
+	_, err = os.Stat(cachePath(specURL) + ".refs")
+	assert.NoError(t, err)
+
+	server.Close()
+
+	// A restart falls back to the cached copy
+	setupTestConfig(t, yaml)
+
+	assert.NoError(t, loadSpecification(ctx))
+	assert.True(t, apis[specURL].Stale)
+	assert.Equal(t, 1.0, staleValue(title))
+
+	_, _, ok := matcher.Load().MatchPath("", "GET", "/api/v1/users/1")
+	assert.True(t, ok)
+
+	// Failed reloads keep the cached copy
+	assert.Error(t, loadSpecification(ctx))
+	assert.True(t, apis[specURL].Stale)
+
+	// A cached copy that can't resolve its references offline is not used
+	assert.NoError(t, os.Remove(cachePath(specURL)+".refs"))
+
+	setupTestConfig(t, yaml)
+
+	assert.Error(t, loadSpecification(ctx))
+	assert.Len(t, apis, 0)
+
+	// Reloads load the current specification once it is reachable again
+	listener, err := net.Listen("tcp", addr)
+	assert.NoError(t, err)
+
+	server = httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	assert.NoError(t, loadSpecification(ctx))
+	assert.False(t, apis[specURL].Stale)
+	assert.Equal(t, 0.0, staleValue(title))
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"api-usage/pkg/expr"
+	"api-usage/pkg/kong"
+	"api-usage/pkg/swagger"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// logFields are the fields of a log available to filters of custom metrics
+var logFields = map[string]bool{
+	"api": true, "path": true, "method": true, "status": true, "status_class": true, "host": true, "uri": true,
+	"header": true, "operation_id": true, "tag": true, "consumer": true, "service": true,
+	"route": true, "workspace": true, "latency": true, "kong_latency": true,
+	"proxy_latency": true, "request_size": true, "response_size": true,
+}
+
+// customMetric is a counter or histogram defined in the config
+type customMetric struct {
+	name      string
+	value     string
+	labels    []customLabel
+	filter    *expr.Expr
+	counter   *prometheus.CounterVec
+	histogram *prometheus.HistogramVec
+}
+
+type customLabel struct {
+	name   string
+	source string
+	header string
+}
+
+var customMetrics []*customMetric
+
+func initCustomMetrics() error {
+	var metrics []*customMetric
+
+	for _, metricConfig := range config.Metrics.Custom {
+		metric := &customMetric{
+			name:  metricConfig.Name,
+			value: metricConfig.Value,
+		}
+
+		if metric.value == "" {
+			metric.value = "one"
+		}
+
+		if metricConfig.Filter != "" {
+			filter, err := expr.Parse(metricConfig.Filter)
+			if err != nil {
+				return fmt.Errorf("custom metric %s: filter: %w", metric.name, err)
+			}
+
+			for _, field := range filter.Fields() {
+				if !logFields[field] {
+					return fmt.Errorf("custom metric %s: filter: unknown field %s", metric.name, field)
+				}
+			}
+
+			metric.filter = filter
+		}
+
+		labelNames := make([]string, 0, len(metricConfig.Labels))
+		for _, labelConfig := range metricConfig.Labels {
+			metric.labels = append(metric.labels, customLabel{
+				name:   labelConfig.Name,
+				source: labelConfig.Source,
+				header: labelConfig.Header,
+			})
+
+			labelNames = append(labelNames, labelConfig.Name)
+		}
+
+		help := metricConfig.Help
+		if help == "" {
+			help = "Custom metric " + metric.name
+		}
+
+		var collector prometheus.Collector
+
+		switch metricConfig.Type {
+		case "counter":
+			metric.counter = prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace:   config.Metrics.Namespace,
+				Subsystem:   config.Metrics.Subsystem,
+				Name:        metric.name,
+				Help:        help,
+				ConstLabels: config.Metrics.ConstLabels,
+			}, labelNames)
+
+			collector = metric.counter
+		case "histogram":
+			metric.histogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace:   config.Metrics.Namespace,
+				Subsystem:   config.Metrics.Subsystem,
+				Name:        metric.name,
+				Help:        help,
+				ConstLabels: config.Metrics.ConstLabels,
+				Buckets:     customBuckets(metricConfig.Buckets, metric.value),
+			}, labelNames)
+
+			collector = metric.histogram
+		}
+
+		if err := prom.Register(collector); err != nil {
+			return fmt.Errorf("custom metric %s: %w", metric.name, err)
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	customMetrics = metrics
+
+	return nil
+}
+
+// customBuckets returns the configured buckets, or defaults fitting the value
+func customBuckets(buckets []float64, value string) []float64 {
+	if len(buckets) > 0 {
+		return buckets
+	}
+
+	switch value {
+	case "latency", "kong_latency", "proxy_latency":
+		return latencyBuckets
+	case "request_size", "response_size":
+		// 128B to 2MB
+		return prometheus.ExponentialBuckets(128, 4, 8)
+	default:
+		return prometheus.DefBuckets
+	}
+}
+
+func recordCustomMetrics(log *kong.Log, api *swagger.API, pathNode *swagger.Node) {
+	env := func(field string, key string) []string {
+		return logFieldValues(log, api, pathNode, field, key)
+	}
+
+	for _, metric := range customMetrics {
+		if metric.filter != nil && !metric.filter.Eval(env) {
+			continue
+		}
+
+		value := customMetricValue(log, metric.value)
+
+		// Kong reports -1 for the latencies of requests it never proxied,
+		// which would make counters go down and panic
+		if value < 0 {
+			continue
+		}
+
+		labelValues := prometheus.Labels{}
+		for _, label := range metric.labels {
+			labelValues[label.name] = customLabelValue(log, api, pathNode, label)
+		}
+
+		limitLabels(labelValues)
+
+		if metric.counter != nil {
+			metric.counter.With(labelValues).Add(value)
+			touchSeries(metric.counter, labelValues)
+		} else {
+			metric.histogram.With(labelValues).Observe(value)
+			touchSeries(metric.histogram, labelValues)
+		}
+	}
+}
+
+func customLabelValue(log *kong.Log, api *swagger.API, pathNode *swagger.Node, label customLabel) string {
+	// Header labels are transformed like the headers of the built-in metrics
+	if label.source == "header" {
+		return headerLabelValue(log, label.header)
+	}
+
+	// Labels take the first value, e.g. the first tag of the operation
+	values := logFieldValues(log, api, pathNode, label.source, "")
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func customMetricValue(log *kong.Log, value string) float64 {
+	switch value {
+	case "latency":
+		return float64(log.Latencies.Request)
+	case "kong_latency":
+		return float64(log.Latencies.Kong)
+	case "proxy_latency":
+		return float64(log.Latencies.Proxy)
+	case "request_size":
+		return float64(log.Request.Size)
+	case "response_size":
+		return float64(log.Response.Size)
+	default:
+		return 1
+	}
+}
+
+// logFieldValues resolves a field of a log matched to an operation
+func logFieldValues(log *kong.Log, api *swagger.API, pathNode *swagger.Node, field string, key string) []string {
+	switch field {
+	case "api":
+		return []string{api.Name}
+	case "path":
+		return []string{pathNode.Path}
+	case "method":
+		return []string{log.Request.Method}
+	case "status":
+		return []string{strconv.Itoa(log.Response.Status)}
+	case "status_class":
+		return []string{strconv.Itoa(log.Response.Status/100) + "xx"}
+	case "host":
+		return []string{log.Request.Headers["host"]}
+	case "uri":
+		return []string{log.Request.URI}
+	case "header":
+		return []string{log.Request.Headers[key]}
+	case "operation_id":
+		return []string{pathNode.OperationID}
+	case "tag":
+		return pathNode.Tags
+	case "consumer":
+		return []string{firstNonEmpty(log.Consumer.Username, log.Consumer.CustomID, log.Consumer.ID)}
+	case "service":
+		return []string{firstNonEmpty(log.Service.Name, log.Service.ID)}
+	case "route":
+		return []string{firstNonEmpty(log.Route.Name, log.Route.ID)}
+	case "workspace":
+		return []string{firstNonEmpty(log.WorkspaceName, log.Workspace)}
+	case "latency", "kong_latency", "proxy_latency", "request_size", "response_size":
+		return []string{strconv.FormatFloat(customMetricValue(log, field), 'f', -1, 64)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"api-usage/pkg/kong"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+const ordersSpec = `
+openapi: 3.0.0
+info:
+  title: Orders
+  version: 1.0.0
+paths:
+  /orders:
+    get:
+      operationId: listOrders
+      tags: [orders, reports]
+      responses:
+        '200':
+          description: Orders
+  /users:
+    get:
+      operationId: listUsers
+      responses:
+        '200':
+          description: Users
+`
+
+// setupCustomMetrics loads the orders specification and initializes the
+// custom metrics of the config
+func setupCustomMetrics(t *testing.T, yaml string) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(ordersSpec), 0o644))
+
+	setupTestConfig(t, `
+openapi: {file: `+file+`}
+`+yaml)
+
+	t.Cleanup(func() {
+		customMetrics = nil
+	})
+
+	assert.NoError(t, initLabels())
+	assert.NoError(t, initCustomMetrics())
+	assert.NoError(t, loadSpecification(context.Background()))
+}
+
+func recordCustomRequest(t *testing.T, log *kong.Log) {
+	t.Helper()
+
+	api, node, ok := matcher.Load().MatchPath("", log.Request.Method, log.Request.URI)
+	assert.True(t, ok)
+
+	recordCustomMetrics(log, api, node)
+}
+
+func TestInitCustomMetrics_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		errMsg string
+	}{
+		{
+			name: "invalid filter",
+			yaml: `
+metrics:
+  custom:
+    - {name: orders_total, type: counter, filter: 'path == '}
+`,
+			errMsg: "custom metric orders_total: filter",
+		},
+		{
+			name: "unknown filter field",
+			yaml: `
+metrics:
+  custom:
+    - {name: orders_total, type: counter, filter: 'body == "x"'}
+`,
+			errMsg: "custom metric orders_total: filter: unknown field body",
+		},
+		{
+			name: "duplicate name",
+			yaml: `
+metrics:
+  custom:
+    - {name: orders_total, type: counter}
+    - {name: orders_total, type: histogram}
+`,
+			errMsg: "custom metric orders_total",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestConfig(t, tt.yaml)
+
+			err := initCustomMetrics()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestRecordCustomMetrics_Filter(t *testing.T) {
+	setupCustomMetrics(t, `
+metrics:
+  custom:
+    - name: mobile_order_requests_total
+      type: counter
+      filter: 'path == "/orders" && header["x-client"] == "mobile" && status >= 200 && status < 300'
+`)
+
+	counter := customMetrics[0].counter
+
+	requests := []struct {
+		uri    string
+		client string
+		status int
+	}{
+		{"/orders", "mobile", 200},
+		{"/orders", "mobile", 201},
+		{"/orders", "web", 200},
+		{"/orders", "mobile", 500},
+		{"/users", "mobile", 200},
+	}
+
+	for _, request := range requests {
+		recordCustomRequest(t, &kong.Log{
+			Request:  kong.Request{Method: "GET", URI: request.uri, Headers: map[string]string{"x-client": request.client}},
+			Response: kong.Response{Status: request.status},
+		})
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(counter))
+}
+
+func TestRecordCustomMetrics_Labels(t *testing.T) {
+	setupCustomMetrics(t, `
+metrics:
+  custom:
+    - name: requests_by_operation_total
+      type: counter
+      labels:
+        - {name: operation, source: operation_id}
+        - {name: tag, source: tag}
+        - {name: class, source: status_class}
+        - {name: client, source: header, header: x-client}
+        - {name: consumer, source: consumer}
+`)
+
+	counter := customMetrics[0].counter
+
+	recordCustomRequest(t, &kong.Log{
+		Request:  kong.Request{Method: "GET", URI: "/orders", Headers: map[string]string{"x-client": "mobile"}},
+		Response: kong.Response{Status: 404},
+		Consumer: kong.Consumer{ID: "1", CustomID: "acme"},
+	})
+	recordCustomRequest(t, &kong.Log{
+		Request:  kong.Request{Method: "GET", URI: "/users"},
+		Response: kong.Response{Status: 200},
+	})
+
+	assert.Equal(t, 2, testutil.CollectAndCount(counter))
+
+	// Tag labels take the first tag, consumers the first identifier set
+	assert.Equal(t, 1.0, testutil.ToFloat64(counter.With(prometheus.Labels{
+		"operation": "listOrders", "tag": "orders", "class": "4xx", "client": "mobile", "consumer": "acme",
+	})))
+	assert.Equal(t, 1.0, testutil.ToFloat64(counter.With(prometheus.Labels{
+		"operation": "listUsers", "tag": "", "class": "2xx", "client": "", "consumer": "",
+	})))
+}
+
+func TestRecordCustomMetrics_Values(t *testing.T) {
+	setupCustomMetrics(t, `
+metrics:
+  custom:
+    - name: proxy_latency_milliseconds_total
+      type: counter
+      value: proxy_latency
+    - name: response_size_bytes
+      type: histogram
+      value: response_size
+      buckets: [100, 1000]
+    - name: requests_total
+      type: counter
+`)
+
+	logs := []*kong.Log{
+		{
+			Request:   kong.Request{Method: "GET", URI: "/orders"},
+			Response:  kong.Response{Status: 200, Size: 50},
+			Latencies: kong.Latencies{Request: 30, Kong: 5, Proxy: 25},
+		},
+		{
+			Request:   kong.Request{Method: "GET", URI: "/orders"},
+			Response:  kong.Response{Status: 200, Size: 500},
+			Latencies: kong.Latencies{Request: 20, Kong: 5, Proxy: 15},
+		},
+		// Kong never proxied the request, e.g. it was rejected by a plugin
+		{
+			Request:   kong.Request{Method: "GET", URI: "/orders"},
+			Response:  kong.Response{Status: 401, Size: 5000},
+			Latencies: kong.Latencies{Request: 1, Kong: 1, Proxy: -1},
+		},
+	}
+
+	for _, log := range logs {
+		recordCustomRequest(t, log)
+	}
+
+	// Negative latencies are skipped, instead of panicking the counter
+	assert.Equal(t, 40.0, testutil.ToFloat64(customMetrics[0].counter))
+	assert.Equal(t, 3.0, testutil.ToFloat64(customMetrics[2].counter))
+
+	assert.NoError(t, testutil.CollectAndCompare(customMetrics[1].histogram, strings.NewReader(`
+# HELP kong_openapi_exporter_response_size_bytes Custom metric response_size_bytes
+# TYPE kong_openapi_exporter_response_size_bytes histogram
+kong_openapi_exporter_response_size_bytes_bucket{le="100"} 1
+kong_openapi_exporter_response_size_bytes_bucket{le="1000"} 2
+kong_openapi_exporter_response_size_bytes_bucket{le="+Inf"} 3
+kong_openapi_exporter_response_size_bytes_sum 5550
+kong_openapi_exporter_response_size_bytes_count 3
+`)))
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"api-usage/pkg/kong"
+	"api-usage/pkg/swagger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// discoveredAPIs are the APIs of kong services by service name, guarded by
+// specMu
+var discoveredAPIs = map[string]*swagger.API{}
+
+// servicesDiscovered is set after the first discovery, so services tagged or
+// untagged later are reported as operation changes. Guarded by specMu.
+var servicesDiscovered bool
+
+func startDiscoveryJob(ctx context.Context) {
+	ticker := time.NewTicker(config.Discovery.Kong.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := discoverSpecifications(ctx); err != nil {
+				logrus.WithError(err).Error("Failed to discover OpenAPI specifications")
+			}
+		case <-ctx.Done():
+			logrus.Debug("OpenAPI specification discovery stopped")
+
+			return
+		}
+	}
+}
+
+// discoverSpecifications lists the kong services tagged with a specification
+// URL, and loads each specification bound to its service
+func discoverSpecifications(ctx context.Context) error {
+	client := &kong.AdminClient{
+		URL:    config.Discovery.Kong.AdminURL,
+		Client: &http.Client{Timeout: config.OpenAPI.Timeout},
+		Token:  config.Discovery.Kong.Token,
+	}
+
+	services, err := client.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Find the specification URL of each service
+	specURLs := map[string]string{}
+	for _, service := range services {
+		specURL, ok := service.TagValue(config.Discovery.Kong.TagPrefix)
+		if !ok || service.Name == "" {
+			continue
+		}
+
+		specURLs[service.Name] = specURL
+	}
+
+	specMu.Lock()
+	defer specMu.Unlock()
+
+	// Drop the APIs of services that are gone or no longer tagged
+	for service, api := range discoveredAPIs {
+		if _, ok := specURLs[service]; !ok {
+			delete(discoveredAPIs, service)
+			delete(urlLoaders, serviceSource(service))
+
+			logrus.WithField("service", service).Info("OpenAPI specification of kong service removed")
+
+			reportOperationChanges(ctx, api.Name, api.Spec, nil)
+		}
+	}
+
+	var errs []error
+	for service, specURL := range specURLs {
+		err := loadAPI(ctx, discoveredAPIs, service, servicesDiscovered, func(ctx context.Context, _ string) (*swagger.Specification, error) {
+			return loadURLWithCache(ctx, serviceSource(service), specURL)
+		})
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if api, ok := discoveredAPIs[service]; ok {
+			api.Service = service
+		}
+	}
+
+	servicesDiscovered = true
+
+	publishAPIs(ctx)
+
+	return errors.Join(errs...)
+}
+
+// serviceSource names the source of the specification of a kong service
+func serviceSource(service string) string {
+	return "kong service " + service
+}
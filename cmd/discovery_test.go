@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+
+	"api-usage/pkg/kong"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/tj/assert"
+)
+
+// newSpecServer serves the test specification with an ETag, answering
+// conditional requests with 304
+func newSpecServer(t *testing.T) *httptest.Server {
+	specBytes, err := os.ReadFile("../testdata/spec.yaml")
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(specBytes)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// fakeAdmin is a kong Admin API listing services
+type fakeAdmin struct {
+	mu       sync.Mutex
+	services []kong.Service
+}
+
+func (a *fakeAdmin) setServices(services ...kong.Service) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.services = services
+}
+
+func (a *fakeAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	jsoniter.NewEncoder(w).Encode(map[string]any{"data": a.services, "next": nil})
+}
+
+func taggedService(name string, specURL string) kong.Service {
+	return kong.Service{ID: name, Name: name, Tags: []string{"openapi-url:" + specURL}}
+}
+
+// publishedServices returns the services of the published APIs
+func publishedServices() []string {
+	var services []string
+	for _, api := range matcher.Load().APIs {
+		services = append(services, api.Service)
+	}
+
+	sort.Strings(services)
+
+	return services
+}
+
+func TestDiscoverSpecifications(t *testing.T) {
+	ctx := context.Background()
+
+	specServer := newSpecServer(t)
+
+	admin := &fakeAdmin{}
+	adminServer := httptest.NewServer(admin)
+	t.Cleanup(adminServer.Close)
+
+	t.Run("services sharing a URL", func(t *testing.T) {
+		setupTestConfig(t, "discovery: {kong: {admin_url: "+adminServer.URL+"}}")
+
+		admin.setServices(taggedService("a", specServer.URL), taggedService("b", specServer.URL))
+
+		// The second load is not modified
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, discoverSpecifications(ctx))
+			assert.Equal(t, []string{"a", "b"}, publishedServices())
+		}
+	})
+
+	t.Run("removed and re-added service", func(t *testing.T) {
+		setupTestConfig(t, "discovery: {kong: {admin_url: "+adminServer.URL+"}}")
+
+		admin.setServices(taggedService("a", specServer.URL))
+		assert.NoError(t, discoverSpecifications(ctx))
+		assert.Equal(t, []string{"a"}, publishedServices())
+
+		ops := float64(len(matcher.Load().APIs[0].Spec.Operations(ctx)))
+
+		admin.setServices(kong.Service{ID: "a", Name: "a"})
+		assert.NoError(t, discoverSpecifications(ctx))
+		assert.Len(t, publishedServices(), 0)
+		assert.Equal(t, ops, operationChanges("Simple OpenAPI 3.0", "removed"))
+
+		admin.setServices(taggedService("a", specServer.URL))
+		assert.NoError(t, discoverSpecifications(ctx))
+		assert.Equal(t, []string{"a"}, publishedServices())
+		assert.Equal(t, ops, operationChanges("Simple OpenAPI 3.0", "added"))
+	})
+
+	t.Run("URL also configured", func(t *testing.T) {
+		setupTestConfig(t, `
+openapi: {url: `+specServer.URL+`}
+discovery: {kong: {admin_url: `+adminServer.URL+`}}
+`)
+
+		assert.NoError(t, loadSpecification(ctx))
+
+		admin.setServices(taggedService("a", specServer.URL))
+		assert.NoError(t, discoverSpecifications(ctx))
+		assert.Equal(t, []string{"", "a"}, publishedServices())
+	})
+}
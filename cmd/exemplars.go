@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"api-usage/pkg/kong"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	// traceparentRegex matches "<version>-<trace-id>-<parent-id>-<flags>",
+	// followed by further fields in future versions
+	traceparentRegex = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}(-|$)`)
+	// b3Regex matches "<trace-id>-<span-id>", optionally followed by the
+	// sampling decision and parent span ID. A lone sampling decision, e.g.
+	// "0", carries no trace ID.
+	b3Regex = regexp.MustCompile(`^([0-9a-f]{32}|[0-9a-f]{16})-[0-9a-f]{16}(-|$)`)
+)
+
+// initExemplars checks the exemplar label, as observing an exemplar with an
+// invalid label panics
+func initExemplars() error {
+	if !labelNameRegex.MatchString(config.Metrics.Exemplars.Label) {
+		return fmt.Errorf("invalid exemplar label %q", config.Metrics.Exemplars.Label)
+	}
+
+	return nil
+}
+
+// requestExemplar returns the exemplar labels of a request, with the ID of the
+// first configured header present, or nil when there is none
+func requestExemplar(log *kong.Log) prometheus.Labels {
+	for _, header := range config.Metrics.Exemplars.Headers {
+		id := traceID(header, log.Request.Headers[header])
+		if id == "" {
+			continue
+		}
+
+		// Exemplar labels are limited to 128 runes, observing longer ones
+		// panics
+		if utf8.RuneCountInString(config.Metrics.Exemplars.Label)+utf8.RuneCountInString(id) > prometheus.ExemplarMaxRunes {
+			continue
+		}
+
+		return prometheus.Labels{config.Metrics.Exemplars.Label: id}
+	}
+
+	return nil
+}
+
+// traceID extracts the trace ID from tracing headers carrying more than that,
+// e.g. "00-<trace-id>-<span-id>-01" of traceparent. Malformed traceparent and
+// b3 headers have no trace ID.
+func traceID(header string, value string) string {
+	var match []string
+
+	switch strings.ToLower(header) {
+	case "traceparent":
+		match = traceparentRegex.FindStringSubmatch(value)
+	case "b3":
+		match = b3Regex.FindStringSubmatch(value)
+	default:
+		return value
+	}
+
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+// observeWithExemplar observes the value, with the exemplar when given
+func observeWithExemplar(observer prometheus.Observer, value float64, exemplar prometheus.Labels) {
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		exemplarObserver.ObserveWithExemplar(value, exemplar)
+
+		return
+	}
+
+	observer.Observe(value)
+}
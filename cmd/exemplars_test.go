@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"api-usage/pkg/kong"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tj/assert"
+)
+
+func TestTraceID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{
+			name:   "traceparent",
+			header: "traceparent",
+			value:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "traceparent header name case",
+			header: "Traceparent",
+			value:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "traceparent of a future version",
+			header: "traceparent",
+			value:  "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-brings",
+			want:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "traceparent without separators",
+			header: "traceparent",
+			value:  "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "traceparent without parent ID",
+			header: "traceparent",
+			value:  "00-4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:   "traceparent with empty trace ID",
+			header: "traceparent",
+			value:  "00--00f067aa0ba902b7-01",
+		},
+		{
+			name:   "traceparent with short trace ID",
+			header: "traceparent",
+			value:  "00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+		},
+		{
+			name:   "traceparent with non hex trace ID",
+			header: "traceparent",
+			value:  "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+		},
+		{
+			name:   "b3",
+			header: "b3",
+			value:  "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90",
+			want:   "80f198ee56343ba864fe8b2a57d3eff7",
+		},
+		{
+			name:   "b3 with 64 bit trace ID",
+			header: "b3",
+			value:  "64fe8b2a57d3eff7-e457b5a2e4d86bd1",
+			want:   "64fe8b2a57d3eff7",
+		},
+		{
+			name:   "b3 sampling decision only",
+			header: "b3",
+			value:  "0",
+		},
+		{
+			name:   "b3 without span ID",
+			header: "b3",
+			value:  "80f198ee56343ba864fe8b2a57d3eff7",
+		},
+		{
+			name:   "other header",
+			header: "x-request-id",
+			value:  "f058ebd6-02f7-4d3f-942e-904344e8cde5",
+			want:   "f058ebd6-02f7-4d3f-942e-904344e8cde5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, traceID(tt.header, tt.value))
+		})
+	}
+}
+
+func TestRequestExemplar(t *testing.T) {
+	setupTestConfig(t, `
+metrics:
+  exemplars:
+    headers: [traceparent, x-request-id]
+`)
+
+	// The label and ID take 128 runes at most, the default label 8 of them
+	maxID := strings.Repeat("a", prometheus.ExemplarMaxRunes-len("trace_id"))
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    prometheus.Labels
+	}{
+		{
+			name:    "no headers",
+			headers: map[string]string{},
+		},
+		{
+			name: "first header",
+			headers: map[string]string{
+				"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"x-request-id": "request",
+			},
+			want: prometheus.Labels{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+		{
+			name: "malformed header",
+			headers: map[string]string{
+				"traceparent":  "malformed",
+				"x-request-id": "request",
+			},
+			want: prometheus.Labels{"trace_id": "request"},
+		},
+		{
+			name:    "longest ID",
+			headers: map[string]string{"x-request-id": maxID},
+			want:    prometheus.Labels{"trace_id": maxID},
+		},
+		{
+			name:    "oversized ID",
+			headers: map[string]string{"x-request-id": maxID + "a"},
+		},
+		{
+			// Runes are counted, not bytes
+			name:    "multibyte ID",
+			headers: map[string]string{"x-request-id": strings.Repeat("é", len(maxID))},
+			want:    prometheus.Labels{"trace_id": strings.Repeat("é", len(maxID))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exemplar := requestExemplar(&kong.Log{Request: kong.Request{Headers: tt.headers}})
+			assert.Equal(t, tt.want, exemplar)
+
+			// Observing the exemplar must not panic
+			if exemplar != nil {
+				histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test"})
+				observeWithExemplar(histogram, 1, exemplar)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"api-usage/pkg/series"
+	"api-usage/pkg/swagger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// seriesTracker is set when idle series expire
+	seriesTracker *series.Tracker
+
+	seriesExpiredTotal *prometheus.CounterVec
+)
+
+// requestVec is a vector with series of requests, deleted when they expire or
+// their operation is removed
+type requestVec interface {
+	series.Vec
+	DeletePartialMatch(labels prometheus.Labels) int
+}
+
+func requestVecs() []requestVec {
+	vecs := []requestVec{
+		httpReqsTotal, httpReqDuration, httpReqErrsTotal, httpReqDurationSummary,
+		sloRequestsTotal, sloGoodRequestsTotal, sloObjective,
+	}
+
+	for _, metric := range customMetrics {
+		if metric.counter != nil {
+			vecs = append(vecs, metric.counter)
+		} else {
+			vecs = append(vecs, metric.histogram)
+		}
+	}
+
+	return vecs
+}
+
+// touchSeries records an update of a series, when idle series expire
+func touchSeries(vec series.Vec, labels prometheus.Labels) {
+	if seriesTracker != nil {
+		seriesTracker.Touch(vec, labels)
+	}
+}
+
+func startSeriesExpiryJob(ctx context.Context) {
+	ticker := time.NewTicker(config.Metrics.Expiry.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			expireSeries()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func expireSeries() {
+	expired := seriesTracker.Expire(config.Metrics.Expiry.TTL)
+	if expired == 0 {
+		return
+	}
+
+	seriesExpiredTotal.With(prometheus.Labels{"reason": "idle"}).Add(float64(expired))
+
+	retainLabelLimits()
+
+	logrus.WithField("series", expired).Debug("Expired idle metric series")
+}
+
+// retainLabelLimits frees the values of label limits that are in none of the
+// tracked series anymore, so deleted series no longer count towards limits
+func retainLabelLimits() {
+	for label, limit := range labelLimits {
+		limit.Retain(seriesTracker.Values(label))
+	}
+}
+
+// deleteRemovedOperationSeries deletes the series of operations that are in
+// none of the published APIs anymore
+func deleteRemovedOperationSeries(ctx context.Context, oldAPIs, newAPIs []*swagger.API) {
+	// Without all of the labels, series of other operations would match
+	apiLabel, apiOK := exportedLabel("api")
+	methodLabel, methodOK := exportedLabel("method")
+	pathLabel, pathOK := exportedLabel("path")
+
+	if !apiOK || !methodOK || !pathOK {
+		return
+	}
+
+	live := map[operationKey]bool{}
+	for _, api := range newAPIs {
+		for _, op := range api.Spec.Operations(ctx) {
+			live[operationKey{api.Name, op.Method, op.Path}] = true
+		}
+	}
+
+	deleted := 0
+	for _, api := range oldAPIs {
+		for _, op := range api.Spec.Operations(ctx) {
+			if live[operationKey{api.Name, op.Method, op.Path}] {
+				continue
+			}
+
+			labels := prometheus.Labels{
+				apiLabel:    api.Name,
+				methodLabel: op.Method,
+				pathLabel:   op.Path,
+			}
+
+			for _, vec := range requestVecs() {
+				deleted += vec.DeletePartialMatch(labels)
+			}
+
+			// The deleted series no longer expire or hold label values
+			if seriesTracker != nil {
+				seriesTracker.Forget(labels)
+			}
+		}
+	}
+
+	if deleted == 0 {
+		return
+	}
+
+	if seriesTracker != nil {
+		retainLabelLimits()
+	}
+
+	seriesExpiredTotal.With(prometheus.Labels{"reason": "removed"}).Add(float64(deleted))
+
+	logrus.WithField("series", deleted).Info("Deleted metric series of removed operations")
+}
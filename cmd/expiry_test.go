@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"api-usage/pkg/kong"
+	"api-usage/pkg/series"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+func recordRequest(t *testing.T, method string, uri string) {
+	t.Helper()
+
+	api, node, ok := matcher.Load().MatchPath("", method, uri)
+	assert.True(t, ok)
+
+	recordMetrics(&kong.Log{
+		Request:  kong.Request{Method: method, URI: uri},
+		Response: kong.Response{Status: 200},
+	}, api, node)
+}
+
+func TestDeleteRemovedOperationSeries(t *testing.T) {
+	ctx := context.Background()
+
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(reportsSpec), 0o644))
+
+	setupTestConfig(t, `
+openapi: {file: `+file+`}
+metrics:
+  expiry: {ttl: 1h}
+  limits:
+    - {label: path, max_values: 1}
+`)
+
+	assert.NoError(t, initLabels())
+
+	seriesTracker = series.NewTracker()
+	t.Cleanup(func() {
+		seriesTracker = nil
+	})
+
+	assert.NoError(t, loadSpecification(ctx))
+	recordRequest(t, "GET", "/reports")
+	assert.Equal(t, 1, testutil.CollectAndCount(httpReqsTotal))
+
+	// Replace /reports with /orders
+	assert.NoError(t, os.WriteFile(file, []byte(strings.ReplaceAll(reportsSpec, "/reports", "/orders")), 0o644))
+	assert.NoError(t, loadSpecification(ctx))
+	assert.Equal(t, 0, testutil.CollectAndCount(httpReqsTotal))
+
+	// The path of the removed operation no longer takes up the only value
+	recordRequest(t, "GET", "/orders")
+	assert.Equal(t, 0.0, testutil.ToFloat64(labelOverflowTotal.With(prometheus.Labels{"label": "path"})))
+	assert.Equal(t, map[string]struct{}{"/orders": {}}, seriesTracker.Values("path"))
+}
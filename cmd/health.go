@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// listening is set once all http listeners are bound, and unset on shutdown
+var listening atomic.Bool
+
+// handleHealthz reports that the process is alive
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// handleReadyz reports whether the exporter can process logs, which requires
+// a valid specification to be loaded and the listeners to be up
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	var reason string
+
+	switch {
+	case len(matcher.Load().APIs) == 0:
+		reason = "no valid OpenAPI specification loaded"
+	case !listening.Load():
+		reason = "listeners not up"
+	case draining.Load():
+		reason = "shutting down"
+	}
+
+	if reason != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(reason + "\n"))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"api-usage/pkg/kong"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// draining is set on shutdown, after which new logs are refused
+	draining atomic.Bool
+	// inflight is read locked by every log being processed, so acquiring
+	// the write lock waits for all of them to finish
+	inflight sync.RWMutex
+)
+
+func ingestHandler() http.Handler {
+	return requireClientCert(authenticateIngest(http.HandlerFunc(handleLog)))
+}
+
+func handleLog(w http.ResponseWriter, r *http.Request) {
+	logrus.Debug("Received log")
+
+	// Refuse new logs while shutting down so kong can retry elsewhere. The
+	// first check keeps them from queueing behind a drain waiting for the
+	// logs in flight, the second refuses those that raced with its start.
+	if refuseDraining(w) {
+		return
+	}
+
+	inflight.RLock()
+	defer inflight.RUnlock()
+
+	if refuseDraining(w) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	log, err := kong.ParseLog(
+		r.Body,
+	)
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to parse log")
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	logrus.WithField("log", *log).Trace("raw log")
+
+	api, pathNode, ok := matcher.Load().MatchPath(log.Service.Name, log.Request.Method, matchURI(log))
+	if ok {
+		recordMetrics(log, api, pathNode)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// refuseDraining answers with 503 and reports true when logs are drained
+func refuseDraining(w http.ResponseWriter) bool {
+	if !draining.Load() {
+		return false
+	}
+
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	return true
+}
+
+// drainLogs stops accepting new logs and waits for the logs in flight to be
+// processed, or for the context to be done.
+func drainLogs(ctx context.Context) error {
+	draining.Store(true)
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Lock()
+		inflight.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// matchURI returns the URI matched against the specifications: the URI of the
+// request to kong, or the URI kong forwarded upstream, after route prefixes
+// were stripped and rewrites applied
+func matchURI(log *kong.Log) string {
+	if config.OpenAPI.Match.URI == "upstream" && log.UpstreamURI != "" {
+		return log.UpstreamURI
+	}
+
+	return log.Request.URI
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+func TestDrainLogs(t *testing.T) {
+	ctx := context.Background()
+
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(reportsSpec), 0o644))
+
+	setupTestConfig(t, `
+openapi: {file: `+file+`}
+`)
+
+	t.Cleanup(func() {
+		draining.Store(false)
+	})
+
+	assert.NoError(t, loadSpecification(ctx))
+
+	// Hold a log in flight until its body is written
+	body, bodyWriter := io.Pipe()
+
+	inflightDone := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		handleLog(w, httptest.NewRequest(http.MethodPost, "/logs", body))
+		inflightDone <- w
+	}()
+
+	_, err := bodyWriter.Write([]byte(`{"request":{"method":"GET",`))
+	assert.NoError(t, err)
+
+	drained := make(chan error)
+	go func() {
+		drained <- drainLogs(ctx)
+	}()
+
+	assert.Eventually(t, draining.Load, time.Second, time.Millisecond)
+
+	// New logs are refused without waiting for the log in flight
+	w := httptest.NewRecorder()
+	handleLog(w, httptest.NewRequest(http.MethodPost, "/logs", strings.NewReader(`{"request":{"method":"GET","uri":"/reports"}}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	select {
+	case <-drained:
+		t.Fatal("drained with a log in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	_, err = bodyWriter.Write([]byte(`"uri":"/reports"},"response":{"status":200}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, bodyWriter.Close())
+
+	assert.NoError(t, <-drained)
+	assert.Equal(t, 1, testutil.CollectAndCount(httpReqsTotal))
+	assert.Equal(t, http.StatusOK, (<-inflightDone).Code)
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+
+	"api-usage/pkg/kong"
+	"api-usage/pkg/labels"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// labelLimits holds the configured limits by label name
+	labelLimits map[string]*labels.Limit
+	// headerTransforms holds the configured transforms by header name
+	headerTransforms map[string]labels.Transform
+
+	labelOverflowTotal *prometheus.CounterVec
+)
+
+func initLabels() error {
+	if err := initLabelLimits(); err != nil {
+		return err
+	}
+
+	return initHeaderTransforms()
+}
+
+func initLabelLimits() error {
+	limits := map[string]*labels.Limit{}
+
+	for _, limitConfig := range config.Metrics.Limits {
+		rewrites := make([]labels.Rewrite, 0, len(limitConfig.Rewrite))
+
+		for _, rewriteConfig := range limitConfig.Rewrite {
+			rewrite, err := labels.NewRewrite(rewriteConfig.Regex, rewriteConfig.Replacement)
+			if err != nil {
+				return fmt.Errorf("label %s: %w", limitConfig.Label, err)
+			}
+
+			rewrites = append(rewrites, rewrite)
+		}
+
+		limits[limitConfig.Label] = labels.NewLimit(limitConfig.MaxValues, limitConfig.Allow, rewrites)
+	}
+
+	labelLimits = limits
+
+	return nil
+}
+
+// limitLabels applies the configured limits to the label values in place,
+// counting the values that overflowed
+func limitLabels(labelValues prometheus.Labels) {
+	for label, value := range labelValues {
+		limit, ok := labelLimits[label]
+		if !ok {
+			continue
+		}
+
+		value, overflowed := limit.Value(value)
+		if overflowed {
+			labelOverflowTotal.With(prometheus.Labels{"label": label}).Inc()
+		}
+
+		labelValues[label] = value
+	}
+}
+
+func initHeaderTransforms() error {
+	chains := map[string][]labels.Transform{}
+
+	for _, transformConfig := range config.Metrics.Transforms {
+		var transform labels.Transform
+
+		switch transformConfig.Type {
+		case "hmac":
+			transform = labels.HMAC([]byte(transformConfig.Secret), transformConfig.Length)
+		case "prefix":
+			transform = labels.Prefix(transformConfig.Length)
+		case "regex":
+			regex, err := regexp.Compile(transformConfig.Regex)
+			if err != nil {
+				return fmt.Errorf("header %s: %w", transformConfig.Header, err)
+			}
+
+			transform = labels.Capture(regex)
+		case "jwt_claim":
+			transform = labels.JWTClaim(transformConfig.Claim)
+		case "lookup":
+			table := map[string]string{}
+			for _, entry := range transformConfig.Table {
+				table[entry.Value] = entry.Label
+			}
+
+			fallback := transformConfig.Default
+			if fallback == "" {
+				fallback = labels.Other
+			}
+
+			transform = labels.Lookup(table, fallback)
+		}
+
+		chains[transformConfig.Header] = append(chains[transformConfig.Header], transform)
+	}
+
+	transforms := map[string]labels.Transform{}
+	for header, chain := range chains {
+		transforms[header] = labels.Chain(chain...)
+	}
+
+	headerTransforms = transforms
+
+	return nil
+}
+
+// headerLabelValue returns the label value of a header, transformed by the
+// configured transforms
+func headerLabelValue(log *kong.Log, header string) string {
+	value := log.Request.Headers[header]
+
+	if transform, ok := headerTransforms[header]; ok {
+		return transform(value)
+	}
+
+	return value
+}
+
+// kongLabels returns the names of the enabled labels resolved by kong
+func kongLabels() []string {
+	kongConfig := config.Metrics.Kong
+
+	var names []string
+
+	if kongConfig.Consumer != "" {
+		names = append(names, "consumer")
+	}
+
+	if kongConfig.Service {
+		names = append(names, "service")
+	}
+
+	if kongConfig.Route {
+		names = append(names, "route")
+	}
+
+	if kongConfig.Workspace {
+		names = append(names, "workspace")
+	}
+
+	return names
+}
+
+// addKongLabels adds the values of the enabled labels resolved by kong. Names
+// fall back to IDs for entities without a name.
+func addKongLabels(labelValues prometheus.Labels, log *kong.Log) {
+	kongConfig := config.Metrics.Kong
+
+	switch kongConfig.Consumer {
+	case "username":
+		labelValues["consumer"] = log.Consumer.Username
+	case "custom_id":
+		labelValues["consumer"] = log.Consumer.CustomID
+	case "id":
+		labelValues["consumer"] = log.Consumer.ID
+	}
+
+	if kongConfig.Service {
+		labelValues["service"] = firstNonEmpty(log.Service.Name, log.Service.ID)
+	}
+
+	if kongConfig.Route {
+		labelValues["route"] = firstNonEmpty(log.Route.Name, log.Route.ID)
+	}
+
+	if kongConfig.Workspace {
+		labelValues["workspace"] = firstNonEmpty(log.WorkspaceName, log.Workspace)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
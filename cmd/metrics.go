@@ -2,17 +2,17 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
-	"time"
+	"syscall"
 
 	"api-usage/pkg/kong"
+	"api-usage/pkg/series"
 	"api-usage/pkg/swagger"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -27,27 +27,90 @@ func init() {
 	rootCmd.AddCommand(metricsCmd)
 }
 
+// latencyBuckets are the histogram buckets of latencies in milliseconds
+var latencyBuckets = []float64{25, 50, 80, 100, 250, 400, 700, 1000, 2000, 5000, 10000, 30000, 60000}
+
 var (
-	spec   *swagger.Specification
 	config *Config
 
-	prom            *prometheus.Registry
-	httpReqsTotal   *prometheus.CounterVec
-	httpReqDuration *prometheus.HistogramVec
+	prom             *prometheus.Registry
+	httpReqsTotal    *prometheus.CounterVec
+	httpReqDuration  *prometheus.HistogramVec
+	httpReqErrsTotal *prometheus.CounterVec
+
+	httpReqDurationSummary *prometheus.SummaryVec
+
+	specOperationChangesTotal *prometheus.CounterVec
+	specInfo                  *prometheus.GaugeVec
+	specOperationInfo         *prometheus.GaugeVec
+	specStale                 *prometheus.GaugeVec
+	specWarningInfo           *prometheus.GaugeVec
 )
 
 func RunMetrics(cmd *cobra.Command, args []string) {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	config = loadConfig()
 
+	// Initialize prometheus metrics
+
+	initMetrics()
+
+	if err := initLabels(); err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize label limits and transforms")
+	}
+
+	if err := initExemplars(); err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize exemplars")
+	}
+
+	if err := initCustomMetrics(); err != nil {
+		logrus.WithError(err).Fatal("Failed to initialize custom metrics")
+	}
+
+	// Expire idle metric series
+
+	if config.Metrics.Expiry.TTL > 0 {
+		seriesTracker = series.NewTracker()
+
+		go startSeriesExpiryJob(ctx)
+	}
+
 	// Load OpenAPI specification
 
-	logrus.WithFields(logrus.Fields{
-		"url": config.OpenAPI.URL,
-	}).Info("Loading OpenAPI specification")
+	if config.OpenAPI.URL != "" || config.OpenAPI.File != "" || config.OpenAPI.Dir != "" {
+		logrus.WithFields(logrus.Fields{
+			"url":  config.OpenAPI.URL,
+			"file": config.OpenAPI.File,
+			"dir":  config.OpenAPI.Dir,
+		}).Info("Loading OpenAPI specification")
+
+		if err := loadSpecification(ctx); err != nil {
+			if len(matcher.Load().APIs) == 0 {
+				logrus.WithError(err).Fatal("Failed to load OpenAPI specification")
+			}
 
-	if err := loadSpecification(ctx); err != nil {
-		logrus.WithError(err).Fatal("Failed to load OpenAPI specification")
+			logrus.WithError(err).Error("Failed to load some OpenAPI specifications")
+		}
+	}
+
+	// Discover OpenAPI specifications of kong services
+
+	if config.Discovery.Kong.AdminURL != "" {
+		logrus.WithFields(logrus.Fields{
+			"admin_url":  config.Discovery.Kong.AdminURL,
+			"tag_prefix": config.Discovery.Kong.TagPrefix,
+			"interval":   config.Discovery.Kong.Interval,
+		}).Info("OpenAPI specification discovery enabled")
+
+		// Failures are not fatal, the exporter stays unready until a
+		// specification is discovered
+		if err := discoverSpecifications(ctx); err != nil {
+			logrus.WithError(err).Error("Failed to discover OpenAPI specifications")
+		}
+
+		go startDiscoveryJob(ctx)
 	}
 
 	// Start auto reload job
@@ -60,193 +123,363 @@ func RunMetrics(cmd *cobra.Command, args []string) {
 		go startReloadSpecificationJob(ctx)
 	}
 
-	// Initialize prometheus metrics
+	// Watch the specification files for changes
 
-	initMetrics()
+	if config.OpenAPI.Watch {
+		if err := watchSpecification(ctx); err != nil {
+			logrus.WithError(err).Fatal("Failed to watch OpenAPI specification")
+		}
+	}
 
-	// Register HTTP handlers
+	// Start http servers, blocking until shutdown
 
-	http.Handle("/metrics", promhttp.HandlerFor(prom, promhttp.HandlerOpts{
-		Registry: prom,
-	}))
+	if err := serve(ctx); err != nil {
+		logrus.WithError(err).Fatal("Failed to serve http")
+	}
 
-	http.Handle("/logs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logrus.Debug("Received log")
+	logrus.Info("Shutdown complete")
+}
 
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+func initMetrics() {
+	// Register prometheus metrics
 
-			return
-		}
+	promInstance := prometheus.NewRegistry()
 
-		log, err := kong.ParseLog(
-			r.Body,
-		)
-		if err != nil {
-			logrus.WithError(err).Debug("Failed to parse log")
-			w.WriteHeader(http.StatusBadRequest)
+	checkBuiltinNames()
 
-			return
-		}
+	// http_requests_total metric
 
-		logrus.WithField("log", *log).Trace("raw log")
+	requestMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("http_requests_total"),
+		Help:        "Total number of HTTP requests",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, requestLabelNames())
 
-		pathNode, ok := spec.MatchPath(log.Request.Method, log.Request.URI)
-		if ok {
-			recordMetrics(log, pathNode)
-		}
+	registerBuiltin(promInstance, "http_requests_total", requestMetric)
 
-		w.WriteHeader(http.StatusOK)
-	}))
+	// http_request_duration_milliseconds
 
-	// Start http server
+	latencyMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("http_request_duration_milliseconds"),
+		Help:        "HTTP request duration in milliseconds",
+		ConstLabels: config.Metrics.ConstLabels,
+		Buckets:     latencyBuckets,
+	}, requestLabelNames())
+
+	registerBuiltin(promInstance, "http_request_duration_milliseconds", latencyMetric)
+
+	// http_request_duration_summary_milliseconds
+
+	summaryMetric := prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("http_request_duration_summary_milliseconds"),
+		Help:        "HTTP request duration quantiles in milliseconds, per operation",
+		ConstLabels: config.Metrics.ConstLabels,
+		Objectives:  summaryObjectives(),
+		MaxAge:      config.Metrics.Summary.MaxAge,
+	}, operationLabelNames())
+
+	if config.Metrics.Summary.Enabled {
+		registerBuiltin(promInstance, "http_request_duration_summary_milliseconds", summaryMetric)
+	}
 
-	logrus.WithFields(logrus.Fields{
-		"port": config.Prometheus.Port,
-		"path": config.Prometheus.Path,
-	}).Info("Starting prometheus server")
+	// http_request_errors_total
 
-	if err := http.ListenAndServe(
-		fmt.Sprintf(":%d", config.Prometheus.Port),
-		nil,
-	); err != nil {
-		logrus.WithError(err).Fatal("Failed to start prometheus server")
-	}
-}
+	errorsMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("http_request_errors_total"),
+		Help:        "Total number of HTTP requests counted as errors, by the configured definition",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, requestLabelNames())
 
-func loadSpecification(ctx context.Context) error {
-	specStartTime := time.Now()
-	isReloading := spec != nil
+	registerBuiltin(promInstance, "http_request_errors_total", errorsMetric)
 
-	var err error
+	// ingest_rejected_total
 
-	if config.OpenAPI.URL != "" {
-		spec, err = swagger.LoadURL(ctx, config.OpenAPI.URL)
-	} else if config.OpenAPI.File != "" {
-		spec, err = swagger.LoadFile(ctx, config.OpenAPI.File)
-	}
-	if err != nil {
-		return err
-	}
+	rejectedMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("ingest_rejected_total"),
+		Help:        "Total number of log requests rejected by ingestion authentication",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"reason"})
 
-	logrus.WithFields(logrus.Fields{
-		"duration": time.Since(specStartTime),
-		"title":    spec.Meta.Title,
-		"version":  spec.Meta.Version,
-	}).Infof("OpenAPI specification %s", func() string {
-		if isReloading {
-			return "reloaded"
-		}
+	registerBuiltin(promInstance, "ingest_rejected_total", rejectedMetric)
 
-		return "loaded"
-	}())
+	// label_overflow_total
 
-	return nil
-}
+	overflowMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("label_overflow_total"),
+		Help:        "Total number of label values collapsed into __other__ by the label limits",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"label"})
 
-func startReloadSpecificationJob(ctx context.Context) {
-	go func() {
-		for {
-			select {
-			case <-time.After(*config.OpenAPI.Reload):
-				loadSpecification(ctx)
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
-}
+	registerBuiltin(promInstance, "label_overflow_total", overflowMetric)
 
-func initMetrics() {
-	// Register prometheus metrics
+	// series_expired_total
 
-	promInstance := prometheus.NewRegistry()
+	expiredMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("series_expired_total"),
+		Help:        "Total number of metric series deleted because they were idle or their operation was removed",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"reason"})
 
-	headerLabels := []string{}
-	if config.Metrics.Headers != nil {
-		for _, header := range *config.Metrics.Headers {
-			headerLabels = append(headerLabels, headerNameToLabelName(header))
-		}
+	registerBuiltin(promInstance, "series_expired_total", expiredMetric)
+
+	// slo_requests_total
+
+	sloRequestsMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("slo_requests_total"),
+		Help:        "Total number of requests of operations with a service level objective",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, sloLabelNames())
+
+	registerBuiltin(promInstance, "slo_requests_total", sloRequestsMetric)
+
+	// slo_good_requests_total
+
+	sloGoodRequestsMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("slo_good_requests_total"),
+		Help:        "Total number of requests meeting the service level objective of their operation",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, sloLabelNames())
+
+	registerBuiltin(promInstance, "slo_good_requests_total", sloGoodRequestsMetric)
+
+	// slo_objective
+
+	sloObjectiveMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("slo_objective"),
+		Help:        "Target ratio of good requests of the service level objective",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, sloLabelNames())
+
+	registerBuiltin(promInstance, "slo_objective", sloObjectiveMetric)
+
+	// slo_burn_rate
+
+	if config.SLO.BurnRate.Enabled {
+		registerBuiltin(promInstance, "slo_burn_rate", newBurnRateCollector())
 	}
 
-	// http_requests_total metric
+	// build_info
 
-	httpRequestsTotalLabels := []string{"host", "method", "status", "path"}
-	httpRequestsTotalLabels = append(httpRequestsTotalLabels, headerLabels...)
+	buildInfoMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("build_info"),
+		Help:        "Build information of the exporter",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"version", "commit", "go_version"})
 
-	requestMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Subsystem: "kong_openapi_exporter",
-		Name:      "http_requests_total",
-		Help:      "Total number of HTTP requests",
-	}, httpRequestsTotalLabels)
+	registerBuiltin(promInstance, "build_info", buildInfoMetric)
 
-	promInstance.MustRegister(requestMetric)
+	// spec_info
 
-	// http_request_duration_milliseconds
+	specInfoMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("spec_info"),
+		Help:        "Title and version of the loaded specifications",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"api", "title", "version"})
 
-	httpRequestDurationLabels := []string{"host", "method", "status", "path"}
-	httpRequestDurationLabels = append(httpRequestDurationLabels, headerLabels...)
+	registerBuiltin(promInstance, "spec_info", specInfoMetric)
 
-	latencyMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Subsystem: "kong_openapi_exporter",
-		Name:      "http_request_duration_milliseconds",
-		Help:      "HTTP request duration in milliseconds",
-		Buckets:   []float64{25, 50, 80, 100, 250, 400, 700, 1000, 2000, 5000, 10000, 30000, 60000},
-	}, httpRequestDurationLabels)
+	// spec_operation_changes_total
+
+	operationChangesMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("spec_operation_changes_total"),
+		Help:        "Total number of operations added, removed or changed by specification reloads",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"api", "kind"})
 
-	promInstance.MustRegister(latencyMetric)
+	registerBuiltin(promInstance, "spec_operation_changes_total", operationChangesMetric)
+
+	// spec_operation_info
+
+	operationInfoMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("spec_operation_info"),
+		Help:        "Operations of the loaded specifications",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"api", "method", "path", "operation_id"})
+
+	registerBuiltin(promInstance, "spec_operation_info", operationInfoMetric)
+
+	// spec_stale
+
+	staleMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("spec_stale"),
+		Help:        "Whether the specification of the API is a stale cached copy (1) or freshly loaded (0)",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"api"})
+
+	registerBuiltin(promInstance, "spec_stale", staleMetric)
+
+	// spec_warning_info
+
+	warningInfoMetric := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   config.Metrics.Namespace,
+		Subsystem:   config.Metrics.Subsystem,
+		Name:        builtinName("spec_warning_info"),
+		Help:        "Problems found in the loaded specifications, matched in a degraded mode",
+		ConstLabels: config.Metrics.ConstLabels,
+	}, []string{"api", "kind", "method", "path"})
+
+	registerBuiltin(promInstance, "spec_warning_info", warningInfoMetric)
 
 	// Assign metrics to global variables
 
 	prom = promInstance
 	httpReqsTotal = requestMetric
 	httpReqDuration = latencyMetric
+	httpReqDurationSummary = summaryMetric
+	httpReqErrsTotal = errorsMetric
+	ingestRejectedTotal = rejectedMetric
+	labelOverflowTotal = overflowMetric
+	seriesExpiredTotal = expiredMetric
+	sloRequestsTotal = sloRequestsMetric
+	sloGoodRequestsTotal = sloGoodRequestsMetric
+	sloObjective = sloObjectiveMetric
+	buildInfo = buildInfoMetric
+	specInfo = specInfoMetric
+	specOperationChangesTotal = operationChangesMetric
+	specOperationInfo = operationInfoMetric
+	specStale = staleMetric
+	specWarningInfo = warningInfoMetric
+
+	setBuildInfo()
 }
 
-func recordMetrics(log *kong.Log, pathNode *swagger.Node) {
-	// Match the path
+func recordMetrics(log *kong.Log, api *swagger.API, pathNode *swagger.Node) {
+	labels := exportLabels(prometheus.Labels{
+		"api":         api.Name,
+		"host":        log.Request.Headers["host"],
+		"method":      log.Request.Method,
+		statusLabel(): statusLabelValue(log.Response.Status),
+		"path":        pathNode.Path,
+	})
+
+	// Add headers to labels
 
-	pathNode, ok := spec.MatchPath(log.Request.Method, log.Request.URI)
-	if !ok {
-		return
+	if config.Metrics.Headers != nil {
+		for _, header := range *config.Metrics.Headers {
+			labels[headerNameToLabelName(header)] = headerLabelValue(log, header)
+		}
 	}
 
-	statusCodeStr := strconv.Itoa(log.Response.Status)
+	// Add labels resolved by kong
+
+	addKongLabels(labels, log)
 
-	// http_requests_total labels
+	// Enforce label limits before any series is created
 
-	httpReqsTotalLabels := prometheus.Labels{
-		"host":   log.Request.Headers["host"],
-		"method": log.Request.Method,
-		"status": statusCodeStr,
-		"path":   pathNode.Path,
+	limitLabels(labels)
+
+	// Increment counters and observe histograms
+
+	if metricEnabled("http_requests_total") {
+		httpReqsTotal.With(labels).Inc()
+		touchSeries(httpReqsTotal, labels)
+	}
+
+	if metricEnabled("http_request_duration_milliseconds") {
+		observeWithExemplar(httpReqDuration.With(labels), float64(log.Latencies.Request), requestExemplar(log))
+		touchSeries(httpReqDuration, labels)
 	}
 
-	// http_request_duration_milliseconds labels
+	isError := isRequestError(log.Response.Status, pathNode)
 
-	httpReqDurationLabels := prometheus.Labels{
-		"host":   log.Request.Headers["host"],
-		"method": log.Request.Method,
-		"status": statusCodeStr,
-		"path":   pathNode.Path,
+	if metricEnabled("http_request_errors_total") && isError {
+		httpReqErrsTotal.With(labels).Inc()
+		touchSeries(httpReqErrsTotal, labels)
 	}
 
-	// Add headers to labels
+	operationLabels := prometheus.Labels{}
+	for _, name := range operationLabelNames() {
+		operationLabels[name] = labels[name]
+	}
 
-	if config.Metrics.Headers != nil {
-		for _, header := range *config.Metrics.Headers {
-			headerLabel := headerNameToLabelName(header)
-			headerValue := log.Request.Headers[header]
+	if config.Metrics.Summary.Enabled && metricEnabled("http_request_duration_summary_milliseconds") {
+		httpReqDurationSummary.With(operationLabels).Observe(float64(log.Latencies.Request))
+		touchSeries(httpReqDurationSummary, operationLabels)
+	}
 
-			httpReqsTotalLabels[headerLabel] = headerValue
-			httpReqDurationLabels[headerLabel] = headerValue
-		}
+	if config.Stats.Enabled {
+		recordStats(api, log.Request.Method, pathNode, float64(log.Latencies.Request), isError)
 	}
 
-	// Increment counters and observe histograms
+	recordSLOs(log, api, pathNode, operationLabels, isError)
+
+	recordCustomMetrics(log, api, pathNode)
+}
+
+// statusLabel returns the name of the status label, "status" for full codes or
+// "status_class" for classes like 2xx
+func statusLabel() string {
+	if config.Metrics.Status == "class" {
+		return "status_class"
+	}
+
+	return "status"
+}
+
+func statusLabelValue(status int) string {
+	if config.Metrics.Status == "class" {
+		return strconv.Itoa(status/100) + "xx"
+	}
+
+	return strconv.Itoa(status)
+}
+
+// isRequestError reports whether a response is an error by the configured
+// definition
+func isRequestError(status int, pathNode *swagger.Node) bool {
+	switch config.Metrics.Errors {
+	case "4xx_5xx":
+		return status >= 400
+	case "undocumented":
+		return !pathNode.DocumentsStatus(status)
+	default:
+		return status >= 500
+	}
+}
+
+// summaryObjectives returns the configured quantiles with their allowed error,
+// or p50, p95 and p99 like /stats when none are configured
+func summaryObjectives() map[float64]float64 {
+	if len(config.Metrics.Summary.Objectives) == 0 {
+		return map[float64]float64{0.5: 0.05, 0.95: 0.01, 0.99: 0.001}
+	}
+
+	objectives := map[float64]float64{}
+	for _, objective := range config.Metrics.Summary.Objectives {
+		objectives[objective.Quantile] = objective.Error
+	}
 
-	httpReqsTotal.With(httpReqsTotalLabels).Inc()
-	httpReqDuration.With(httpReqDurationLabels).Observe(float64(log.Latencies.Request))
+	return objectives
 }
 
 func headerNameToLabelName(header string) string {
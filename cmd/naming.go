@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// builtinMetrics are the names of the built-in metrics, which can be renamed
+// or disabled
+var builtinMetrics = []string{
+	"http_requests_total",
+	"http_request_duration_milliseconds",
+	"http_request_duration_summary_milliseconds",
+	"http_request_errors_total",
+	"ingest_rejected_total",
+	"label_overflow_total",
+	"series_expired_total",
+	"slo_requests_total",
+	"slo_good_requests_total",
+	"slo_objective",
+	"slo_burn_rate",
+	"build_info",
+	"spec_info",
+	"spec_operation_changes_total",
+	"spec_operation_info",
+	"spec_stale",
+	"spec_warning_info",
+}
+
+// builtinLabels are the names of the built-in labels of the request metrics,
+// which can be renamed or disabled
+var builtinLabels = []string{"api", "host", "method", "status", "status_class", "path"}
+
+// checkBuiltinNames fails on renamed or disabled metrics and labels that do
+// not exist, which are most likely typos
+func checkBuiltinNames() {
+	for name := range config.Metrics.Rename {
+		if !slices.Contains(builtinMetrics, name) {
+			logrus.WithField("metric", name).Fatal("Failed to rename unknown metric")
+		}
+	}
+
+	for _, name := range config.Metrics.Disable {
+		if !slices.Contains(builtinMetrics, name) {
+			logrus.WithField("metric", name).Fatal("Failed to disable unknown metric")
+		}
+	}
+
+	for name := range config.Metrics.RenameLabels {
+		if !slices.Contains(builtinLabels, name) {
+			logrus.WithField("label", name).Fatal("Failed to rename unknown label")
+		}
+	}
+
+	for _, name := range config.Metrics.DisableLabels {
+		if !slices.Contains(builtinLabels, name) {
+			logrus.WithField("label", name).Fatal("Failed to disable unknown label")
+		}
+	}
+}
+
+// builtinName returns the configured name of a built-in metric
+func builtinName(name string) string {
+	if renamed, ok := config.Metrics.Rename[name]; ok {
+		return renamed
+	}
+
+	return name
+}
+
+func metricEnabled(name string) bool {
+	return !slices.Contains(config.Metrics.Disable, name)
+}
+
+// registerBuiltin registers a built-in metric unless it is disabled
+func registerBuiltin(registry *prometheus.Registry, name string, collector prometheus.Collector) {
+	if metricEnabled(name) {
+		registry.MustRegister(collector)
+	}
+}
+
+// exportedLabel returns the configured name of a built-in label, or false when
+// it is disabled
+func exportedLabel(name string) (string, bool) {
+	if slices.Contains(config.Metrics.DisableLabels, name) {
+		return "", false
+	}
+
+	if renamed, ok := config.Metrics.RenameLabels[name]; ok {
+		return renamed, true
+	}
+
+	return name, true
+}
+
+// exportLabels renames the built-in labels and drops the disabled ones
+func exportLabels(builtin prometheus.Labels) prometheus.Labels {
+	labels := make(prometheus.Labels, len(builtin))
+
+	for name, value := range builtin {
+		if exported, ok := exportedLabel(name); ok {
+			labels[exported] = value
+		}
+	}
+
+	return labels
+}
+
+// requestLabelNames returns the label names of the request metrics
+func requestLabelNames() []string {
+	var names []string
+
+	for _, name := range []string{"api", "host", "method", statusLabel(), "path"} {
+		if exported, ok := exportedLabel(name); ok {
+			names = append(names, exported)
+		}
+	}
+
+	if config.Metrics.Headers != nil {
+		for _, header := range *config.Metrics.Headers {
+			names = append(names, headerNameToLabelName(header))
+		}
+	}
+
+	return append(names, kongLabels()...)
+}
+
+// operationLabelNames returns the label names identifying an operation in
+// the request metrics
+func operationLabelNames() []string {
+	var names []string
+
+	for _, name := range []string{"api", "method", "path"} {
+		if exported, ok := exportedLabel(name); ok {
+			names = append(names, exported)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"api-usage/pkg/kong"
+
+	"github.com/tj/assert"
+)
+
+// gatheredLabels returns the label names of the gathered metrics by metric
+// name
+func gatheredLabels(t *testing.T) map[string][]string {
+	t.Helper()
+
+	families, err := prom.Gather()
+	assert.NoError(t, err)
+
+	labels := map[string][]string{}
+	for _, family := range families {
+		names := []string{}
+		for _, label := range family.GetMetric()[0].GetLabel() {
+			names = append(names, label.GetName())
+		}
+
+		labels[family.GetName()] = names
+	}
+
+	return labels
+}
+
+func TestBuiltinNames(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(reportsSpec), 0o644))
+
+	setupTestConfig(t, `
+openapi: {file: `+file+`}
+metrics:
+  rename:
+    http_requests_total: api_requests_total
+    spec_operation_info: operation_info
+  disable: [http_request_errors_total, http_request_duration_summary_milliseconds]
+  rename_labels:
+    path: route_path
+    status: code
+  disable_labels: [host]
+`)
+
+	assert.NoError(t, loadSpecification(context.Background()))
+
+	api, node, ok := matcher.Load().MatchPath("", "GET", "/reports")
+	assert.True(t, ok)
+
+	// Errors are recorded to the disabled error metric without panicking
+	recordMetrics(&kong.Log{
+		Request:  kong.Request{Method: "GET", URI: "/reports", Headers: map[string]string{"host": "example.com"}},
+		Response: kong.Response{Status: 500},
+	}, api, node)
+
+	labels := gatheredLabels(t)
+
+	assert.Equal(t, []string{"api", "code", "method", "route_path"}, labels["kong_openapi_exporter_api_requests_total"])
+	assert.Equal(t, []string{"api", "code", "method", "route_path"}, labels["kong_openapi_exporter_http_request_duration_milliseconds"])
+
+	// Only the labels of the request metrics are renamed
+	assert.Equal(t, []string{"api", "method", "operation_id", "path"}, labels["kong_openapi_exporter_operation_info"])
+
+	for _, name := range []string{
+		"kong_openapi_exporter_http_requests_total",
+		"kong_openapi_exporter_http_request_errors_total",
+		"kong_openapi_exporter_http_request_duration_summary_milliseconds",
+		"kong_openapi_exporter_spec_operation_info",
+	} {
+		_, ok := labels[name]
+		assert.False(t, ok, name)
+	}
+}
+
+func TestRequestLabelNames(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"api", "host", "method", "status", "path"},
+		},
+		{
+			name: "status class",
+			yaml: `
+metrics:
+  status: class
+`,
+			want: []string{"api", "host", "method", "status_class", "path"},
+		},
+		{
+			name: "renamed status class",
+			yaml: `
+metrics:
+  status: class
+  rename_labels: {status_class: class, api: service}
+`,
+			want: []string{"service", "host", "method", "class", "path"},
+		},
+		{
+			name: "disabled",
+			yaml: `
+metrics:
+  disable_labels: [api, host, path]
+`,
+			want: []string{"method", "status"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestConfig(t, tt.yaml)
+
+			assert.Equal(t, tt.want, requestLabelNames())
+		})
+	}
+}
@@ -14,23 +14,188 @@ import (
 
 var cfgFile string
 
+// Listener configures the HTTP server of an endpoint. Endpoints without an
+// address are served by the metrics server.
+type Listener struct {
+	Address      string        `mapstructure:"address" validate:"omitempty,hostname_port"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout" default:"10s"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout" default:"10s"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout" default:"60s"`
+}
+
 type Config struct {
 	Log struct {
 		Level  string `mapstructure:"level" default:"info" validate:"oneof=panic fatal error warn info debug trace"`
 		Format string `mapstructure:"format" default:"json" validate:"oneof=text json"`
 	} `mapstructure:"log"`
 	OpenAPI struct {
-		URL    string         `mapstructure:"url" validate:"required_without=File,omitempty,url"`
-		File   string         `mapstructure:"file" validate:"required_without=URL,omitempty,filepath"`
-		Reload *time.Duration `mapstructure:"reload,omitempty"`
+		URL      string         `mapstructure:"url" validate:"excluded_with=File Dir,omitempty,url"`
+		File     string         `mapstructure:"file" validate:"excluded_with=Dir,omitempty,filepath"`
+		Dir      string         `mapstructure:"dir" validate:"omitempty,dirpath"`
+		Pattern  string         `mapstructure:"pattern"`
+		Reload   *time.Duration `mapstructure:"reload,omitempty" validate:"omitempty,min=1s"`
+		Watch    bool           `mapstructure:"watch" default:"true"`
+		Debounce time.Duration  `mapstructure:"debounce" default:"1s"`
+		Timeout  time.Duration  `mapstructure:"timeout" default:"30s"`
+		Retries  int            `mapstructure:"retries" default:"3" validate:"min=0"`
+		Backoff  time.Duration  `mapstructure:"backoff" default:"1s"`
+		Auth     struct {
+			Headers         map[string]string `mapstructure:"headers"`
+			BearerTokenFile string            `mapstructure:"bearer_token_file" validate:"excluded_with=BearerTokenEnv,omitempty,filepath"`
+			BearerTokenEnv  string            `mapstructure:"bearer_token_env"`
+			Username        string            `mapstructure:"username"`
+			Password        string            `mapstructure:"password"`
+		} `mapstructure:"auth"`
+		Cache struct {
+			Dir string `mapstructure:"dir"`
+		} `mapstructure:"cache"`
+		Match struct {
+			URI      string `mapstructure:"uri" default:"request" validate:"oneof=request upstream"`
+			Prefixes []struct {
+				API      string   `mapstructure:"api"`
+				Prefixes []string `mapstructure:"prefixes" validate:"dive,startswith=/"`
+			} `mapstructure:"prefixes" validate:"dive"`
+		} `mapstructure:"match"`
+		Refs struct {
+			Relative    bool     `mapstructure:"relative" default:"true"`
+			RemoteHosts []string `mapstructure:"remote_hosts"`
+		} `mapstructure:"refs"`
+		TLS struct {
+			CAFile             string `mapstructure:"ca_file" validate:"omitempty,filepath"`
+			CertFile           string `mapstructure:"cert_file" validate:"required_with=KeyFile,omitempty,filepath"`
+			KeyFile            string `mapstructure:"key_file" validate:"required_with=CertFile,omitempty,filepath"`
+			InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+		} `mapstructure:"tls"`
 	} `mapstructure:"openapi"`
 	Prometheus struct {
-		Path string `mapstructure:"path" default:"/metrics"`
-		Port int    `mapstructure:"port" default:"9090"`
-	}
+		Listener `mapstructure:",squash"`
+		Path     string `mapstructure:"path" default:"/metrics" validate:"startswith=/"`
+		Port     int    `mapstructure:"port" default:"9090"`
+	} `mapstructure:"prometheus"`
+	Admin struct {
+		Listener `mapstructure:",squash"`
+		Path     string `mapstructure:"path" default:"/" validate:"startswith=/"`
+	} `mapstructure:"admin"`
+	Stats struct {
+		Enabled bool          `mapstructure:"enabled"`
+		Window  time.Duration `mapstructure:"window" default:"5m" validate:"min=1s"`
+	} `mapstructure:"stats"`
+	SLO struct {
+		Objectives []struct {
+			Name        string        `mapstructure:"name" validate:"required"`
+			API         string        `mapstructure:"api"`
+			Method      string        `mapstructure:"method"`
+			Path        string        `mapstructure:"path"`
+			OperationID string        `mapstructure:"operation_id"`
+			Target      float64       `mapstructure:"target" validate:"gt=0,lt=1"`
+			Latency     time.Duration `mapstructure:"latency" validate:"min=0"`
+		} `mapstructure:"objectives" validate:"dive"`
+		BurnRate struct {
+			Enabled bool            `mapstructure:"enabled"`
+			Windows []time.Duration `mapstructure:"windows" validate:"dive,min=1s"`
+		} `mapstructure:"burn_rate"`
+	} `mapstructure:"slo"`
+	Shutdown struct {
+		Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+	} `mapstructure:"shutdown"`
+	TLS struct {
+		CertFile     string        `mapstructure:"cert_file" validate:"required_with=KeyFile,omitempty,filepath"`
+		KeyFile      string        `mapstructure:"key_file" validate:"required_with=CertFile,omitempty,filepath"`
+		ClientCAFile string        `mapstructure:"client_ca_file" validate:"excluded_without=CertFile,omitempty,filepath"`
+		Reload       time.Duration `mapstructure:"reload" default:"1m" validate:"min=1s"`
+	} `mapstructure:"tls"`
 	Metrics struct {
-		Headers *[]string `mapstructure:"headers,omitempty"`
-	}
+		Namespace     string            `mapstructure:"namespace"`
+		Subsystem     string            `mapstructure:"subsystem" default:"kong_openapi_exporter"`
+		ConstLabels   map[string]string `mapstructure:"const_labels"`
+		Rename        map[string]string `mapstructure:"rename"`
+		Disable       []string          `mapstructure:"disable"`
+		RenameLabels  map[string]string `mapstructure:"rename_labels"`
+		DisableLabels []string          `mapstructure:"disable_labels"`
+		Headers       *[]string         `mapstructure:"headers,omitempty"`
+		Status        string            `mapstructure:"status" default:"code" validate:"oneof=code class"`
+		Errors        string            `mapstructure:"errors" default:"5xx" validate:"oneof=5xx 4xx_5xx undocumented"`
+		Summary       struct {
+			Enabled    bool `mapstructure:"enabled"`
+			Objectives []struct {
+				Quantile float64 `mapstructure:"quantile" validate:"gt=0,lt=1"`
+				Error    float64 `mapstructure:"error" validate:"gt=0,lt=1"`
+			} `mapstructure:"objectives" validate:"dive"`
+			MaxAge time.Duration `mapstructure:"max_age" default:"10m"`
+		} `mapstructure:"summary"`
+		Exemplars struct {
+			Headers []string `mapstructure:"headers"`
+			Label   string   `mapstructure:"label" default:"trace_id" validate:"required"`
+		} `mapstructure:"exemplars"`
+		Expiry struct {
+			TTL      time.Duration `mapstructure:"ttl"`
+			Interval time.Duration `mapstructure:"interval" default:"1m" validate:"min=1s"`
+		} `mapstructure:"expiry"`
+		Kong struct {
+			Consumer  string `mapstructure:"consumer" validate:"omitempty,oneof=username custom_id id"`
+			Service   bool   `mapstructure:"service"`
+			Route     bool   `mapstructure:"route"`
+			Workspace bool   `mapstructure:"workspace"`
+		} `mapstructure:"kong"`
+		Limits []struct {
+			Label     string   `mapstructure:"label" validate:"required"`
+			MaxValues int      `mapstructure:"max_values" validate:"min=0"`
+			Allow     []string `mapstructure:"allow"`
+			Rewrite   []struct {
+				Regex       string `mapstructure:"regex" validate:"required"`
+				Replacement string `mapstructure:"replacement"`
+			} `mapstructure:"rewrite" validate:"dive"`
+		} `mapstructure:"limits" validate:"dive"`
+		Transforms []struct {
+			Header string `mapstructure:"header" validate:"required"`
+			Type   string `mapstructure:"type" validate:"oneof=hmac prefix regex jwt_claim lookup"`
+			Secret string `mapstructure:"secret" validate:"required_if=Type hmac"`
+			Length int    `mapstructure:"length" validate:"min=0,required_if=Type prefix"`
+			Regex  string `mapstructure:"regex" validate:"required_if=Type regex"`
+			Claim  string `mapstructure:"claim" validate:"required_if=Type jwt_claim"`
+			Table  []struct {
+				Value string `mapstructure:"value"`
+				Label string `mapstructure:"label" validate:"required"`
+			} `mapstructure:"table" validate:"required_if=Type lookup,dive"`
+			Default string `mapstructure:"default"`
+		} `mapstructure:"transforms" validate:"dive"`
+		Custom []struct {
+			Name    string    `mapstructure:"name" validate:"required"`
+			Type    string    `mapstructure:"type" validate:"oneof=counter histogram"`
+			Help    string    `mapstructure:"help"`
+			Value   string    `mapstructure:"value" validate:"omitempty,oneof=one latency kong_latency proxy_latency request_size response_size"`
+			Buckets []float64 `mapstructure:"buckets"`
+			Labels  []struct {
+				Name   string `mapstructure:"name" validate:"required"`
+				Source string `mapstructure:"source" validate:"oneof=api path method status status_class host header operation_id tag consumer service route workspace"`
+				Header string `mapstructure:"header" validate:"required_if=Source header"`
+			} `mapstructure:"labels" validate:"dive"`
+			Filter string `mapstructure:"filter"`
+		} `mapstructure:"custom" validate:"dive"`
+	} `mapstructure:"metrics"`
+	Ingest struct {
+		Listener `mapstructure:",squash"`
+		Path     string `mapstructure:"path" default:"/logs" validate:"startswith=/"`
+		Auth     struct {
+			Mode  string `mapstructure:"mode" default:"none" validate:"oneof=none bearer basic hmac"`
+			Token string `mapstructure:"token" validate:"required_if=Mode bearer"`
+			Users []struct {
+				Username     string `mapstructure:"username" validate:"required"`
+				PasswordHash string `mapstructure:"password_hash" validate:"required"`
+			} `mapstructure:"users" validate:"required_if=Mode basic,dive"`
+			Secret    string `mapstructure:"secret" validate:"required_if=Mode hmac"`
+			Header    string `mapstructure:"header" default:"X-Signature"`
+			Algorithm string `mapstructure:"algorithm" default:"sha256" validate:"oneof=sha256 sha512"`
+		} `mapstructure:"auth"`
+	} `mapstructure:"ingest"`
+	Discovery struct {
+		Kong struct {
+			AdminURL  string        `mapstructure:"admin_url" validate:"omitempty,url"`
+			Token     string        `mapstructure:"token"`
+			TagPrefix string        `mapstructure:"tag_prefix" default:"openapi-url:" validate:"required"`
+			Interval  time.Duration `mapstructure:"interval" default:"1m" validate:"min=1s"`
+		} `mapstructure:"kong"`
+	} `mapstructure:"discovery"`
 }
 
 var rootCmd = &cobra.Command{
@@ -70,6 +235,11 @@ func loadConfig() *Config {
 		logrus.WithError(err).Fatal("Failed to validate config")
 	}
 
+	if config.OpenAPI.URL == "" && config.OpenAPI.File == "" && config.OpenAPI.Dir == "" &&
+		config.Discovery.Kong.AdminURL == "" {
+		logrus.Fatal("Failed to validate config: one of openapi.url, openapi.file, openapi.dir or discovery.kong.admin_url is required")
+	}
+
 	setupLogger(&config)
 
 	return &config
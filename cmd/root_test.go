@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"api-usage/pkg/swagger"
+
+	"github.com/creasty/defaults"
+	"github.com/spf13/viper"
+	"github.com/tj/assert"
+)
+
+// setupTestConfig sets the global config from the YAML with defaults applied,
+// initializes the metrics and forgets the loaded specifications. The previous
+// config is restored when the test ends.
+func setupTestConfig(t *testing.T, yaml string) *Config {
+	t.Helper()
+
+	previous := config
+
+	var testConfig Config
+	assert.NoError(t, defaults.Set(&testConfig))
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(strings.NewReader(yaml)))
+	assert.NoError(t, v.Unmarshal(&testConfig))
+
+	config = &testConfig
+	initMetrics()
+
+	apis = map[string]*swagger.API{}
+	discoveredAPIs = map[string]*swagger.API{}
+	urlLoaders = map[string]*swagger.URLLoader{}
+	specsLoaded = false
+	servicesDiscovered = false
+	matcher.Store(swagger.NewMatcher(nil))
+
+	t.Cleanup(func() {
+		config = previous
+	})
+
+	return config
+}
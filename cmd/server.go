@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// serve starts the metrics, ingestion and admin servers and blocks until one
+// of them fails or the context is done, after which the servers are shut down
+// gracefully. Endpoints without their own address share the metrics server.
+func serve(ctx context.Context) error {
+	var tlsConfig *tls.Config
+	if config.TLS.CertFile != "" {
+		reloader, err := newCertReloader(config.TLS.CertFile, config.TLS.KeyFile, config.TLS.ClientCAFile)
+		if err != nil {
+			return err
+		}
+
+		go reloader.watch(ctx, config.TLS.Reload)
+
+		tlsConfig = reloader.tlsConfig()
+	}
+
+	// Metrics server
+
+	metricsListener := config.Prometheus.Listener
+	if metricsListener.Address == "" {
+		metricsListener.Address = fmt.Sprintf(":%d", config.Prometheus.Port)
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle(config.Prometheus.Path, promhttp.HandlerFor(prom, promhttp.HandlerOpts{
+		Registry: prom,
+		// Exemplars are only exposed in the OpenMetrics format
+		EnableOpenMetrics: true,
+	}))
+
+	servers := map[string]*http.Server{
+		"metrics": newServer(metricsListener, metricsMux, tlsConfig),
+	}
+
+	// Ingestion server
+
+	ingestMux := metricsMux
+	if config.Ingest.Address != "" {
+		ingestMux = http.NewServeMux()
+		servers["ingest"] = newServer(config.Ingest.Listener, ingestMux, tlsConfig)
+	}
+
+	ingestMux.Handle(config.Ingest.Path, ingestHandler())
+
+	// Admin server
+
+	adminMux := metricsMux
+	if config.Admin.Address != "" {
+		adminMux = http.NewServeMux()
+		servers["admin"] = newServer(config.Admin.Listener, adminMux, tlsConfig)
+	}
+
+	registerAdminHandlers(adminMux, config.Admin.Path)
+
+	// Bind all listeners before serving, so readiness reflects all of them
+
+	listeners := map[string]net.Listener{}
+	for name, server := range servers {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			return fmt.Errorf("%s server: %w", name, err)
+		}
+
+		listeners[name] = listener
+	}
+
+	listening.Store(true)
+
+	// Start the servers
+
+	errs := make(chan error, len(servers))
+
+	for name, server := range servers {
+		logrus.WithFields(logrus.Fields{
+			"server":  name,
+			"address": server.Addr,
+			"tls":     server.TLSConfig != nil,
+		}).Info("Starting http server")
+
+		go func(server *http.Server, listener net.Listener) {
+			if err := serveListener(server, listener); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}(server, listeners[name])
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	return shutdown(servers)
+}
+
+// shutdown drains the logs in flight and shuts the servers down within the
+// configured shutdown timeout.
+func shutdown(servers map[string]*http.Server) error {
+	logrus.WithField("timeout", config.Shutdown.Timeout).Info("Shutting down")
+
+	listening.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Shutdown.Timeout)
+	defer cancel()
+
+	if err := drainLogs(ctx); err != nil {
+		logrus.WithError(err).Warn("Timed out draining logs in flight")
+	}
+
+	var errs []error
+	for name, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s server: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func newServer(listener Listener, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:         listener.Address,
+		Handler:      handler,
+		TLSConfig:    tlsConfig,
+		ReadTimeout:  listener.ReadTimeout,
+		WriteTimeout: listener.WriteTimeout,
+		IdleTimeout:  listener.IdleTimeout,
+	}
+}
+
+func serveListener(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		return server.ServeTLS(listener, "", "")
+	}
+
+	return server.Serve(listener)
+}
+
+// registerAdminHandlers mounts the admin endpoints below the given prefix
+func registerAdminHandlers(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	mux.Handle(prefix+"/healthz", http.HandlerFunc(handleHealthz))
+	mux.Handle(prefix+"/readyz", http.HandlerFunc(handleReadyz))
+
+	if config.Stats.Enabled {
+		mux.Handle(prefix+"/stats", http.HandlerFunc(handleStats))
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"api-usage/pkg/kong"
+	"api-usage/pkg/stats"
+	"api-usage/pkg/swagger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// sloBurnRateWindows are the burn rate windows used when none are configured
+var sloBurnRateWindows = []time.Duration{5 * time.Minute, time.Hour, 6 * time.Hour}
+
+var (
+	sloRequestsTotal     *prometheus.CounterVec
+	sloGoodRequestsTotal *prometheus.CounterVec
+	sloObjective         *prometheus.GaugeVec
+)
+
+// sloKey identifies an objective of an operation
+type sloKey struct {
+	slo string
+	operationKey
+}
+
+// sloBurnRate tracks the bad requests of an objective over the burn rate
+// windows
+type sloBurnRate struct {
+	labels  prometheus.Labels
+	target  float64
+	windows []*stats.Window
+}
+
+var (
+	sloBurnRatesMu sync.Mutex
+	sloBurnRates   = map[sloKey]*sloBurnRate{}
+)
+
+// sloLabelNames returns the label names of the SLO metrics
+func sloLabelNames() []string {
+	return append([]string{"slo"}, operationLabelNames()...)
+}
+
+// burnRateWindows returns the configured burn rate windows, or 5m, 1h and 6h
+// when none are configured
+func burnRateWindows() []time.Duration {
+	if len(config.SLO.BurnRate.Windows) == 0 {
+		return sloBurnRateWindows
+	}
+
+	return config.SLO.BurnRate.Windows
+}
+
+// operationSLOs returns the objectives of an operation, declared by its x-slo
+// extension or the config. Objectives of the config replace the declared ones
+// with the same name.
+func operationSLOs(api *swagger.API, method string, pathNode *swagger.Node) []swagger.SLO {
+	slos := []swagger.SLO{}
+
+	for _, objective := range config.SLO.Objectives {
+		if objective.API != "" && objective.API != api.Name {
+			continue
+		}
+
+		if objective.Method != "" && !strings.EqualFold(objective.Method, method) {
+			continue
+		}
+
+		if objective.Path != "" && objective.Path != pathNode.Path {
+			continue
+		}
+
+		if objective.OperationID != "" && objective.OperationID != pathNode.OperationID {
+			continue
+		}
+
+		slos = append(slos, swagger.SLO{
+			Name:    objective.Name,
+			Target:  objective.Target,
+			Latency: objective.Latency,
+		})
+	}
+
+	for _, slo := range pathNode.SLOs {
+		overridden := false
+		for _, configured := range slos {
+			if configured.Name == slo.Name {
+				overridden = true
+
+				break
+			}
+		}
+
+		if !overridden {
+			slos = append(slos, slo)
+		}
+	}
+
+	return slos
+}
+
+// recordSLOs counts the request as good or bad for every objective of its
+// operation. Errors are bad by the configured error definition, and so are
+// requests slower than the latency of the objective.
+func recordSLOs(log *kong.Log, api *swagger.API, pathNode *swagger.Node, operationLabels prometheus.Labels, isError bool) {
+	if len(config.SLO.Objectives) == 0 && len(pathNode.SLOs) == 0 {
+		return
+	}
+
+	latency := time.Duration(log.Latencies.Request) * time.Millisecond
+
+	for _, slo := range operationSLOs(api, log.Request.Method, pathNode) {
+		good := !isError && (slo.Latency == 0 || latency <= slo.Latency)
+
+		labels := prometheus.Labels{"slo": slo.Name}
+		for name, value := range operationLabels {
+			labels[name] = value
+		}
+
+		if metricEnabled("slo_objective") {
+			sloObjective.With(labels).Set(slo.Target)
+			touchSeries(sloObjective, labels)
+		}
+
+		if metricEnabled("slo_requests_total") {
+			sloRequestsTotal.With(labels).Inc()
+			touchSeries(sloRequestsTotal, labels)
+		}
+
+		if metricEnabled("slo_good_requests_total") && good {
+			sloGoodRequestsTotal.With(labels).Inc()
+			touchSeries(sloGoodRequestsTotal, labels)
+		}
+
+		if config.SLO.BurnRate.Enabled {
+			observeBurnRate(sloKey{slo.Name, operationKey{api.Name, log.Request.Method, pathNode.Path}}, labels, slo.Target, !good)
+		}
+	}
+}
+
+func observeBurnRate(key sloKey, labels prometheus.Labels, target float64, bad bool) {
+	sloBurnRatesMu.Lock()
+
+	burnRate, ok := sloBurnRates[key]
+	if !ok {
+		burnRate = &sloBurnRate{labels: labels}
+
+		for _, window := range burnRateWindows() {
+			burnRate.windows = append(burnRate.windows, stats.NewWindow(window, statsSlots, statsStart))
+		}
+
+		sloBurnRates[key] = burnRate
+	}
+
+	// The target may change on reloads of the specification
+	burnRate.target = target
+
+	sloBurnRatesMu.Unlock()
+
+	for _, window := range burnRate.windows {
+		window.Observe(0, bad)
+	}
+}
+
+// burnRateCollector collects the burn rates of the objectives, the ratio of
+// bad requests in each window relative to the error budget of the objective
+type burnRateCollector struct {
+	desc *prometheus.Desc
+}
+
+func newBurnRateCollector() *burnRateCollector {
+	return &burnRateCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(config.Metrics.Namespace, config.Metrics.Subsystem, builtinName("slo_burn_rate")),
+			"Rate the error budget of the objective is spent at over the window, 1 spends it exactly",
+			append(sloLabelNames(), "window"),
+			config.Metrics.ConstLabels,
+		),
+	}
+}
+
+func (c *burnRateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *burnRateCollector) Collect(ch chan<- prometheus.Metric) {
+	sloBurnRatesMu.Lock()
+	defer sloBurnRatesMu.Unlock()
+
+	windows := burnRateWindows()
+
+	for key, burnRate := range sloBurnRates {
+		snapshots := make([]stats.Snapshot, len(burnRate.windows))
+
+		idle := true
+		for i, window := range burnRate.windows {
+			snapshots[i] = window.Snapshot(nil)
+
+			if snapshots[i].Requests > 0 {
+				idle = false
+			}
+		}
+
+		// Forget objectives without requests in any window
+		if idle {
+			delete(sloBurnRates, key)
+
+			continue
+		}
+
+		for i, snapshot := range snapshots {
+			if snapshot.Requests == 0 {
+				continue
+			}
+
+			labelValues := make([]string, 0, len(burnRate.labels)+1)
+			for _, name := range sloLabelNames() {
+				labelValues = append(labelValues, burnRate.labels[name])
+			}
+
+			labelValues = append(labelValues, model.Duration(windows[i]).String())
+
+			ch <- prometheus.MustNewConstMetric(
+				c.desc,
+				prometheus.GaugeValue,
+				snapshot.ErrorRatio/(1-burnRate.target),
+				labelValues...,
+			)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"api-usage/pkg/kong"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+const sloSpec = `
+openapi: 3.0.0
+info:
+  title: Reports
+  version: 1.0.0
+paths:
+  /reports:
+    get:
+      x-slo:
+        target: 0.9
+        latency: 100ms
+      responses:
+        '200':
+          description: Reports
+`
+
+// setupSLOs loads the reports specification with a latency objective and
+// forgets the burn rates when the test ends
+func setupSLOs(t *testing.T, yaml string) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte(sloSpec), 0o644))
+
+	setupTestConfig(t, `
+openapi: {file: `+file+`}
+`+yaml)
+
+	t.Cleanup(func() {
+		sloBurnRatesMu.Lock()
+		sloBurnRates = map[sloKey]*sloBurnRate{}
+		sloBurnRatesMu.Unlock()
+	})
+
+	assert.NoError(t, initLabels())
+	assert.NoError(t, loadSpecification(context.Background()))
+}
+
+func recordReport(t *testing.T, latency int, status int) {
+	t.Helper()
+
+	api, node, ok := matcher.Load().MatchPath("", "GET", "/reports")
+	assert.True(t, ok)
+
+	recordMetrics(&kong.Log{
+		Request:   kong.Request{Method: "GET", URI: "/reports"},
+		Response:  kong.Response{Status: status},
+		Latencies: kong.Latencies{Request: latency},
+	}, api, node)
+}
+
+func sloLabels(slo string) prometheus.Labels {
+	return prometheus.Labels{"slo": slo, "api": "Reports", "method": "GET", "path": "/reports"}
+}
+
+func TestRecordSLOs(t *testing.T) {
+	setupSLOs(t, `
+slo:
+  objectives:
+    - {name: availability, path: /reports, target: 0.99}
+`)
+
+	recordReport(t, 50, 200)
+	// Too slow for the latency objective
+	recordReport(t, 150, 200)
+	recordReport(t, 100, 200)
+	// An error is bad for both objectives, however fast
+	recordReport(t, 10, 500)
+
+	assert.Equal(t, 4.0, testutil.ToFloat64(sloRequestsTotal.With(sloLabels("latency"))))
+	assert.Equal(t, 2.0, testutil.ToFloat64(sloGoodRequestsTotal.With(sloLabels("latency"))))
+	assert.Equal(t, 0.9, testutil.ToFloat64(sloObjective.With(sloLabels("latency"))))
+
+	assert.Equal(t, 4.0, testutil.ToFloat64(sloRequestsTotal.With(sloLabels("availability"))))
+	assert.Equal(t, 3.0, testutil.ToFloat64(sloGoodRequestsTotal.With(sloLabels("availability"))))
+	assert.Equal(t, 0.99, testutil.ToFloat64(sloObjective.With(sloLabels("availability"))))
+}
+
+func TestRecordSLOs_ConfigReplacesDeclared(t *testing.T) {
+	setupSLOs(t, `
+slo:
+  objectives:
+    - {name: latency, path: /reports, target: 0.95, latency: 200ms}
+`)
+
+	recordReport(t, 150, 200)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(sloObjective))
+	assert.Equal(t, 0.95, testutil.ToFloat64(sloObjective.With(sloLabels("latency"))))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sloGoodRequestsTotal.With(sloLabels("latency"))))
+}
+
+func TestBurnRateCollector(t *testing.T) {
+	setupSLOs(t, `
+slo:
+  objectives:
+    - {name: latency, path: /reports, target: 0.75, latency: 100ms}
+  burn_rate:
+    enabled: true
+    windows: [5m, 1h]
+`)
+
+	recordReport(t, 50, 200)
+	recordReport(t, 50, 200)
+	recordReport(t, 150, 200)
+	recordReport(t, 50, 500)
+
+	// Half of the requests are bad, twice the budget of a quarter
+	assert.NoError(t, testutil.CollectAndCompare(newBurnRateCollector(), strings.NewReader(`
+# HELP kong_openapi_exporter_slo_burn_rate Rate the error budget of the objective is spent at over the window, 1 spends it exactly
+# TYPE kong_openapi_exporter_slo_burn_rate gauge
+kong_openapi_exporter_slo_burn_rate{api="Reports",method="GET",path="/reports",slo="latency",window="1h"} 2
+kong_openapi_exporter_slo_burn_rate{api="Reports",method="GET",path="/reports",slo="latency",window="5m"} 2
+`)))
+}
+
+func TestBurnRateCollector_Idle(t *testing.T) {
+	setupSLOs(t, `
+slo:
+  burn_rate:
+    enabled: true
+    windows: [1s]
+`)
+
+	collector := newBurnRateCollector()
+
+	recordReport(t, 50, 200)
+	assert.Equal(t, 1, testutil.CollectAndCount(collector))
+
+	// Objectives without requests in any window are forgotten
+	time.Sleep(1100 * time.Millisecond)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+	assert.Len(t, sloBurnRates, 0)
+}
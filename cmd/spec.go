@@ -0,0 +1,479 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-usage/pkg/swagger"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// matcher matches logs against the loaded specifications, swapped
+	// atomically once all specifications are built
+	matcher atomic.Pointer[swagger.Matcher]
+
+	// specMu serializes loads, which can be triggered by the reload job, the
+	// file watcher and the discovery job, and guards the maps below
+	specMu sync.Mutex
+	// apis are the loaded APIs by the file or URL they are loaded from
+	apis = map[string]*swagger.API{}
+	// urlLoaders are reused between reloads for conditional requests, by
+	// the source they load. Sources sharing a URL have their own loaders, as
+	// a load that is not modified only keeps the specification of its own
+	// source.
+	urlLoaders = map[string]*swagger.URLLoader{}
+	// specsLoaded is set once the configured specifications were loaded, so
+	// specifications added or removed later are reported as operation changes
+	specsLoaded bool
+)
+
+func init() {
+	// Start without APIs until the first specification is loaded
+	matcher.Store(swagger.NewMatcher(nil))
+}
+
+// loadSpecification loads the configured specifications and swaps them in
+// once they are all built. A specification that fails to load keeps its
+// previously loaded version.
+func loadSpecification(ctx context.Context) error {
+	specMu.Lock()
+	defer specMu.Unlock()
+
+	var err error
+
+	switch {
+	case config.OpenAPI.URL != "":
+		err = loadAPI(ctx, apis, config.OpenAPI.URL, specsLoaded, func(ctx context.Context, url string) (*swagger.Specification, error) {
+			return loadURLWithCache(ctx, url, url)
+		})
+	case config.OpenAPI.File != "":
+		err = loadAPI(ctx, apis, config.OpenAPI.File, specsLoaded, loadSpecificationFile)
+	case config.OpenAPI.Dir != "":
+		err = loadSpecificationDir(ctx)
+	}
+
+	specsLoaded = true
+
+	publishAPIs(ctx)
+
+	return err
+}
+
+// loadAPI loads the specification of a single source into the given map. On
+// reloads, the operations of a source that was not loaded before are reported
+// as added.
+func loadAPI(
+	ctx context.Context,
+	into map[string]*swagger.API,
+	source string,
+	reload bool,
+	load func(ctx context.Context, source string) (*swagger.Specification, error),
+) error {
+	specStartTime := time.Now()
+	previous, isReloading := into[source]
+
+	newSpec, err := load(ctx, source)
+	if errors.Is(err, swagger.ErrNotModified) {
+		if !isReloading {
+			return fmt.Errorf("%s: not modified, but not loaded before", source)
+		}
+
+		logrus.WithField("source", source).Debug("OpenAPI specification not modified")
+
+		return nil
+	}
+
+	// Fall back to the cached specification only when there is no
+	// previously loaded one to keep
+	var stale *staleError
+	if errors.As(err, &stale) && !isReloading {
+		logrus.WithError(stale.err).WithField("source", source).Warn("Using cached OpenAPI specification")
+
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	into[source] = &swagger.API{
+		Name:  newSpec.Meta.Title,
+		Spec:  newSpec,
+		Stale: stale != nil,
+	}
+
+	for _, warning := range newSpec.Warnings {
+		logrus.WithFields(logrus.Fields{
+			"source": source,
+			"kind":   warning.Kind,
+			"method": warning.Method,
+			"path":   warning.Path,
+		}).Warn(warning.Message)
+	}
+
+	if isReloading {
+		reportOperationChanges(ctx, newSpec.Meta.Title, previous.Spec, newSpec)
+	} else if reload {
+		reportOperationChanges(ctx, newSpec.Meta.Title, nil, newSpec)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"source":   source,
+		"duration": time.Since(specStartTime),
+		"title":    newSpec.Meta.Title,
+		"version":  newSpec.Meta.Version,
+	}).Infof("OpenAPI specification %s", func() string {
+		if isReloading {
+			return "reloaded"
+		}
+
+		return "loaded"
+	}())
+
+	return nil
+}
+
+// loadSpecificationDir loads every specification file in the configured
+// directory, dropping the APIs of files that were removed
+func loadSpecificationDir(ctx context.Context) error {
+	files, err := specificationFiles()
+	if err != nil {
+		return err
+	}
+
+	for source, api := range apis {
+		if !slices.Contains(files, source) {
+			delete(apis, source)
+
+			logrus.WithField("source", source).Info("OpenAPI specification removed")
+
+			reportOperationChanges(ctx, api.Name, api.Spec, nil)
+		}
+	}
+
+	var errs []error
+	for _, file := range files {
+		if err := loadAPI(ctx, apis, file, specsLoaded, loadSpecificationFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// specificationFiles lists the files in the configured directory matching the
+// configured pattern, or with a YAML or JSON extension when no pattern is set
+func specificationFiles() ([]string, error) {
+	entries, err := os.ReadDir(config.OpenAPI.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		// Skip directories and hidden files, like the "..data" link of ConfigMaps
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		if !isSpecificationFile(entry.Name()) {
+			continue
+		}
+
+		files = append(files, filepath.Join(config.OpenAPI.Dir, entry.Name()))
+	}
+
+	return files, nil
+}
+
+func isSpecificationFile(name string) bool {
+	if config.OpenAPI.Pattern != "" {
+		ok, _ := filepath.Match(config.OpenAPI.Pattern, name)
+
+		return ok
+	}
+
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+// publishAPIs swaps in a matcher for the loaded and discovered APIs
+func publishAPIs(ctx context.Context) {
+	loaded := make([]*swagger.API, 0, len(apis)+len(discoveredAPIs))
+	names := map[string]string{}
+
+	sources := maps.Clone(apis)
+	for service, api := range discoveredAPIs {
+		sources[serviceSource(service)] = api
+	}
+
+	for source, api := range sources {
+		if other, ok := names[api.Name]; ok {
+			logrus.WithFields(logrus.Fields{
+				"title":   api.Name,
+				"sources": []string{other, source},
+			}).Warn("Multiple OpenAPI specifications share a title, their metrics are merged")
+		}
+
+		names[api.Name] = source
+
+		// Copy the API, as the published APIs are read concurrently
+		published := *api
+		published.Prefixes = matchPrefixes(api)
+
+		loaded = append(loaded, &published)
+	}
+
+	newMatcher := swagger.NewMatcher(loaded)
+
+	oldMatcher := matcher.Swap(newMatcher)
+
+	deleteRemovedOperationSeries(ctx, oldMatcher.APIs, newMatcher.APIs)
+
+	setSpecInfo(newMatcher.APIs)
+	setOperationInfo(ctx, newMatcher.APIs)
+	setStaleInfo(newMatcher.APIs)
+	setWarningInfo(newMatcher.APIs)
+}
+
+// setWarningInfo reports the warnings of the APIs in the spec_warning_info
+// gauge
+func setWarningInfo(apis []*swagger.API) {
+	specWarningInfo.Reset()
+
+	for _, api := range apis {
+		for _, warning := range api.Spec.Warnings {
+			specWarningInfo.With(prometheus.Labels{
+				"api":    api.Name,
+				"kind":   warning.Kind,
+				"method": warning.Method,
+				"path":   warning.Path,
+			}).Set(1)
+		}
+	}
+}
+
+// setSpecInfo reports the title and version of the APIs in the spec_info
+// gauge
+func setSpecInfo(apis []*swagger.API) {
+	specInfo.Reset()
+
+	for _, api := range apis {
+		specInfo.With(prometheus.Labels{
+			"api":     api.Name,
+			"title":   api.Spec.Meta.Title,
+			"version": api.Spec.Meta.Version,
+		}).Set(1)
+	}
+}
+
+// setStaleInfo reports which APIs use a cached specification
+func setStaleInfo(apis []*swagger.API) {
+	specStale.Reset()
+
+	for _, api := range apis {
+		value := 0.0
+		if api.Stale {
+			value = 1
+		}
+
+		specStale.With(prometheus.Labels{"api": api.Name}).Set(value)
+	}
+}
+
+// reportOperationChanges logs and counts the operations added, removed or
+// changed by a reload. A nil specification stands for a source that was added
+// or removed.
+func reportOperationChanges(ctx context.Context, api string, oldSpec, newSpec *swagger.Specification) {
+	for _, change := range swagger.DiffOperations(ctx, oldSpec, newSpec) {
+		logrus.WithFields(logrus.Fields{
+			"api":          api,
+			"kind":         change.Kind,
+			"method":       change.Operation.Method,
+			"path":         change.Operation.Path,
+			"operation_id": change.Operation.OperationID,
+		}).Infof("OpenAPI operation %s", change.Kind)
+
+		specOperationChangesTotal.With(prometheus.Labels{
+			"api":  api,
+			"kind": change.Kind,
+		}).Inc()
+	}
+}
+
+// setOperationInfo reports the operations of the APIs in the
+// spec_operation_info gauge
+func setOperationInfo(ctx context.Context, apis []*swagger.API) {
+	specOperationInfo.Reset()
+
+	for _, api := range apis {
+		for _, op := range api.Spec.Operations(ctx) {
+			specOperationInfo.With(prometheus.Labels{
+				"api":          api.Name,
+				"method":       op.Method,
+				"path":         op.Path,
+				"operation_id": op.OperationID,
+			}).Set(1)
+		}
+	}
+}
+
+// matchPrefixes returns the configured prefixes of the API, selected by its
+// title or kong service. Entries without an API apply to all APIs.
+func matchPrefixes(api *swagger.API) []string {
+	var prefixes []string
+
+	for _, entry := range config.OpenAPI.Match.Prefixes {
+		if entry.API == "" || entry.API == api.Name || (api.Service != "" && entry.API == api.Service) {
+			prefixes = append(prefixes, entry.Prefixes...)
+		}
+	}
+
+	return prefixes
+}
+
+func loadSpecificationFile(ctx context.Context, file string) (*swagger.Specification, error) {
+	return swagger.LoadFileWithRefs(ctx, file, refOptions())
+}
+
+// loadSpecificationURL loads the specification of the source from the given
+// URL
+func loadSpecificationURL(ctx context.Context, source string, url string) (*swagger.Specification, error) {
+	loader, err := urlLoader(source, url)
+	if err != nil {
+		return nil, err
+	}
+
+	return loader.Load(ctx)
+}
+
+// urlLoader returns the loader of the source, reused between reloads for
+// conditional requests as long as the URL of the source stays the same
+func urlLoader(source string, url string) (*swagger.URLLoader, error) {
+	if loader, ok := urlLoaders[source]; ok && loader.URL == url {
+		return loader, nil
+	}
+
+	loader, err := newURLLoader(url)
+	if err != nil {
+		return nil, err
+	}
+
+	urlLoaders[source] = loader
+
+	return loader, nil
+}
+
+// watchSpecification reloads the specifications when the configured file or
+// the files in the configured directory change
+func watchSpecification(ctx context.Context) error {
+	reload := func() {
+		if err := loadSpecification(ctx); err != nil {
+			logrus.WithError(err).Error("Failed to reload OpenAPI specification")
+		}
+	}
+
+	switch {
+	case config.OpenAPI.File != "":
+		logrus.WithFields(logrus.Fields{
+			"file":     config.OpenAPI.File,
+			"debounce": config.OpenAPI.Debounce,
+		}).Info("OpenAPI specification file watch enabled")
+
+		return watchFile(ctx, config.OpenAPI.File, config.OpenAPI.Debounce, reload)
+	case config.OpenAPI.Dir != "":
+		logrus.WithFields(logrus.Fields{
+			"dir":      config.OpenAPI.Dir,
+			"debounce": config.OpenAPI.Debounce,
+		}).Info("OpenAPI specification directory watch enabled")
+
+		// Any change may add, remove or swap specification files
+		return watchDir(ctx, config.OpenAPI.Dir, config.OpenAPI.Debounce, func(fsnotify.Event) bool {
+			return true
+		}, reload)
+	}
+
+	return nil
+}
+
+// newURLLoader creates a loader for the given URL with the configured
+// timeouts, retries, credentials and TLS settings
+func newURLLoader(url string) (*swagger.URLLoader, error) {
+	loader := swagger.NewURLLoader(url)
+	loader.Client.Timeout = config.OpenAPI.Timeout
+	loader.Retries = config.OpenAPI.Retries
+	loader.Backoff = config.OpenAPI.Backoff
+	loader.Refs = refOptions()
+
+	auth := config.OpenAPI.Auth
+
+	loader.Header = http.Header{}
+	for name, value := range auth.Headers {
+		loader.Header.Set(name, value)
+	}
+
+	loader.Username = auth.Username
+	loader.Password = auth.Password
+
+	if auth.BearerTokenFile != "" {
+		loader.Token = swagger.FileToken(auth.BearerTokenFile)
+	} else if auth.BearerTokenEnv != "" {
+		loader.Token = swagger.EnvToken(auth.BearerTokenEnv)
+	}
+
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		loader.Client.Transport = transport
+	}
+
+	return loader, nil
+}
+
+func startReloadSpecificationJob(ctx context.Context) {
+	ticker := time.NewTicker(*config.OpenAPI.Reload)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := loadSpecification(ctx); err != nil {
+				logrus.WithError(err).Error("Failed to reload OpenAPI specification")
+			}
+		case <-ctx.Done():
+			logrus.Debug("OpenAPI specification auto reload stopped")
+
+			return
+		}
+	}
+}
+
+func refOptions() swagger.RefOptions {
+	return swagger.RefOptions{
+		Relative:    config.OpenAPI.Refs.Relative,
+		RemoteHosts: config.OpenAPI.Refs.RemoteHosts,
+		Timeout:     config.OpenAPI.Timeout,
+	}
+}
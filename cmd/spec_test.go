@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+const reportsSpec = `
+openapi: 3.0.0
+info:
+  title: Reports
+  version: 1.0.0
+paths:
+  /reports:
+    get:
+      responses:
+        '200':
+          description: Reports
+`
+
+func operationChanges(api string, kind string) float64 {
+	return testutil.ToFloat64(specOperationChangesTotal.With(prometheus.Labels{"api": api, "kind": kind}))
+}
+
+func TestLoadSpecificationDir_OperationChanges(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+
+	specBytes, err := os.ReadFile("../testdata/spec.yaml")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "users.yaml"), specBytes, 0o644))
+
+	setupTestConfig(t, "openapi: {dir: "+dir+"}")
+
+	// Operations of the initial load are not changes
+	assert.NoError(t, loadSpecification(ctx))
+	assert.Equal(t, 0.0, operationChanges("Simple OpenAPI 3.0", "added"))
+
+	usersOps := len(apis[filepath.Join(dir, "users.yaml")].Spec.Operations(ctx))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "reports.yaml"), []byte(reportsSpec), 0o644))
+	assert.NoError(t, loadSpecification(ctx))
+	assert.Equal(t, 1.0, operationChanges("Reports", "added"))
+	assert.Equal(t, 0.0, operationChanges("Simple OpenAPI 3.0", "added"))
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "users.yaml")))
+	assert.NoError(t, loadSpecification(ctx))
+	assert.Equal(t, float64(usersOps), operationChanges("Simple OpenAPI 3.0", "removed"))
+	assert.Equal(t, 0.0, operationChanges("Reports", "removed"))
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"api-usage/pkg/stats"
+	"api-usage/pkg/swagger"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// statsSlots is the number of slots the stats window is split into, which
+// expire one at a time
+const statsSlots = 30
+
+// statsQuantiles are the latency quantiles reported by /stats
+var statsQuantiles = []float64{0.5, 0.95, 0.99}
+
+// operationKey identifies an operation of an API
+type operationKey struct {
+	api, method, path string
+}
+
+type operationWindow struct {
+	operationID string
+	window      *stats.Window
+}
+
+var (
+	// statsStart is when collecting stats started, the start of all windows
+	statsStart = time.Now()
+
+	operationStatsMu sync.Mutex
+	operationStats   = map[operationKey]*operationWindow{}
+)
+
+type statsResponse struct {
+	Window     string          `json:"window"`
+	Operations []operationStat `json:"operations"`
+}
+
+type operationStat struct {
+	API         string             `json:"api"`
+	Method      string             `json:"method"`
+	Path        string             `json:"path"`
+	OperationID string             `json:"operation_id,omitempty"`
+	Requests    uint64             `json:"requests"`
+	Errors      uint64             `json:"errors"`
+	RequestRate float64            `json:"request_rate"`
+	ErrorRate   float64            `json:"error_rate"`
+	ErrorRatio  float64            `json:"error_ratio"`
+	Latency     map[string]float64 `json:"latency_milliseconds"`
+}
+
+// recordStats adds a request to the sliding window of its operation
+func recordStats(api *swagger.API, method string, pathNode *swagger.Node, latency float64, isError bool) {
+	key := operationKey{api.Name, method, pathNode.Path}
+
+	operationStatsMu.Lock()
+
+	operation, ok := operationStats[key]
+	if !ok {
+		operation = &operationWindow{
+			operationID: pathNode.OperationID,
+			window:      stats.NewWindow(config.Stats.Window, statsSlots, statsStart),
+		}
+
+		operationStats[key] = operation
+	}
+
+	operationStatsMu.Unlock()
+
+	operation.window.Observe(latency, isError)
+}
+
+// handleStats reports the request rates, error rates and latency quantiles of
+// the operations with requests in the window
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	response := statsResponse{
+		Window:     config.Stats.Window.String(),
+		Operations: []operationStat{},
+	}
+
+	operationStatsMu.Lock()
+
+	for key, operation := range operationStats {
+		snapshot := operation.window.Snapshot(statsQuantiles)
+
+		// Forget operations without requests in the window
+		if snapshot.Requests == 0 {
+			delete(operationStats, key)
+
+			continue
+		}
+
+		latency := map[string]float64{}
+		for _, q := range statsQuantiles {
+			latency[quantileName(q)] = math.Round(snapshot.Quantiles[q]*1000) / 1000
+		}
+
+		response.Operations = append(response.Operations, operationStat{
+			API:         key.api,
+			Method:      key.method,
+			Path:        key.path,
+			OperationID: operation.operationID,
+			Requests:    snapshot.Requests,
+			Errors:      snapshot.Errors,
+			RequestRate: snapshot.RequestRate,
+			ErrorRate:   snapshot.ErrorRate,
+			ErrorRatio:  snapshot.ErrorRatio,
+			Latency:     latency,
+		})
+	}
+
+	operationStatsMu.Unlock()
+
+	sort.Slice(response.Operations, func(i, j int) bool {
+		a, b := response.Operations[i], response.Operations[j]
+		if a.API != b.API {
+			return a.API < b.API
+		}
+
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+
+		return a.Method < b.Method
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	jsoniter.ConfigCompatibleWithStandardLibrary.NewEncoder(w).Encode(response)
+}
+
+// quantileName names a quantile as a percentile, e.g. p95 for 0.95
+func quantileName(q float64) string {
+	return "p" + strconv.FormatFloat(q*100, 'f', -1, 64)
+}
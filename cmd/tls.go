@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// certReloader serves the server certificate and client CA pool from disk,
+// reloading them when the files are modified, e.g. on rotation.
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time
+}
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	reloader := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if c.clientCAFile != "" {
+		caBytes, err := os.ReadFile(c.clientCAFile)
+		if err != nil {
+			return err
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return fmt.Errorf("no certificates found in %s", c.clientCAFile)
+		}
+	}
+
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cert = &cert
+	c.clientCAs = clientCAs
+	c.modTime = modTime
+
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the watched files
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range []string{c.certFile, c.keyFile, c.clientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-time.After(interval):
+			modTime, err := c.latestModTime()
+			if err != nil {
+				logrus.WithError(err).Error("Failed to stat TLS certificates")
+
+				continue
+			}
+
+			c.mu.RLock()
+			changed := !modTime.Equal(c.modTime)
+			c.mu.RUnlock()
+
+			if !changed {
+				continue
+			}
+
+			if err := c.load(); err != nil {
+				logrus.WithError(err).Error("Failed to reload TLS certificates")
+
+				continue
+			}
+
+			logrus.Info("TLS certificates reloaded")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Servers started without certificate files need a certificate in
+		// the outer config, even though GetConfigForClient replaces it
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			return c.cert, nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*c.cert},
+			}
+
+			// Client certificates are verified when given, and required
+			// per handler by requireClientCert
+			if c.clientCAs != nil {
+				config.ClientCAs = c.clientCAs
+				config.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+
+			return config, nil
+		},
+	}
+}
+
+func requireClientCert(next http.Handler) http.Handler {
+	if config.TLS.ClientCAFile == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			rejectIngest(w, rejectMissingClientCert)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientTLSConfig returns the TLS configuration used to fetch specifications,
+// or nil when the defaults apply. The client certificate is read from disk on
+// every handshake, so rotated certificates are picked up.
+func clientTLSConfig() (*tls.Config, error) {
+	tlsOptions := config.OpenAPI.TLS
+
+	if tlsOptions.CAFile == "" && tlsOptions.CertFile == "" && !tlsOptions.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: tlsOptions.InsecureSkipVerify,
+	}
+
+	if tlsOptions.CAFile != "" {
+		caBytes, err := os.ReadFile(tlsOptions.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in %s", tlsOptions.CAFile)
+		}
+	}
+
+	if tlsOptions.CertFile != "" {
+		// Fail early on a broken key pair
+		if _, err := tls.LoadX509KeyPair(tlsOptions.CertFile, tlsOptions.KeyFile); err != nil {
+			return nil, err
+		}
+
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(tlsOptions.CertFile, tlsOptions.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+
+			return &cert, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
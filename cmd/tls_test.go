@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+// testCert is a certificate with its key, signed by a test CA
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// writePEM writes the certificate and key to files in the directory
+func (c *testCert) writePEM(t *testing.T, dir string, name string) (certFile string, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestServeTLS_ClientCert(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+
+	server := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "exporter"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "kong"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	certFile, keyFile := server.writePEM(t, dir, "server")
+
+	setupTestConfig(t, `
+tls:
+  cert_file: `+certFile+`
+  key_file: `+keyFile+`
+  client_ca_file: `+caFile+`
+`)
+
+	reloader, err := newCertReloader(certFile, keyFile, caFile)
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/logs", ingestHandler())
+
+	httpServer := newServer(Listener{}, mux, reloader.tlsConfig())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serveListener(httpServer, listener)
+	}()
+
+	t.Cleanup(func() {
+		httpServer.Close()
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	post := func(certificates []tls.Certificate) int {
+		httpClient := &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				RootCAs:      roots,
+				Certificates: certificates,
+			}},
+		}
+
+		resp, err := httpClient.Post("https://"+listener.Addr().String()+"/logs", "application/json",
+			strings.NewReader(`{"request":{"uri":"/","method":"GET"},"response":{"status":200}}`))
+		if err != nil {
+			select {
+			case err := <-serveErr:
+				t.Fatalf("serve: %v", err)
+			default:
+			}
+
+			t.Fatalf("post: %v", err)
+		}
+
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post(nil))
+	assert.Equal(t, 1.0, rejected(rejectMissingClientCert))
+
+	assert.Equal(t, http.StatusOK, post([]tls.Certificate{client.tlsCertificate()}))
+}
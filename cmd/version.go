@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Set at build time with
+// -ldflags "-X api-usage/cmd.version=<version> -X api-usage/cmd.commit=<commit>"
+var (
+	version = "dev"
+	commit  = ""
+)
+
+var buildInfo *prometheus.GaugeVec
+
+// buildCommit returns the commit set at build time, falling back to the VCS
+// revision embedded by the go toolchain
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return "unknown"
+}
+
+// setBuildInfo reports the build of the exporter in the build_info gauge
+func setBuildInfo() {
+	buildInfo.With(prometheus.Labels{
+		"version":    version,
+		"commit":     buildCommit(),
+		"go_version": runtime.Version(),
+	}).Set(1)
+}
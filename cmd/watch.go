@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// watchFile calls onChange when the file changes, debouncing bursts of
+// events. The parent directory is watched rather than the file itself, so
+// atomic replacements and Kubernetes ConfigMap symlink swaps, which replace
+// the "..data" symlink next to the file, are detected too.
+func watchFile(ctx context.Context, path string, debounce time.Duration, onChange func()) error {
+	path = filepath.Clean(path)
+
+	// The resolved path changes when a symlink in the path is swapped
+	target, _ := filepath.EvalSymlinks(path)
+
+	return watchDir(ctx, filepath.Dir(path), debounce, func(event fsnotify.Event) bool {
+		newTarget, _ := filepath.EvalSymlinks(path)
+		if filepath.Clean(event.Name) != path && newTarget == target {
+			return false
+		}
+
+		target = newTarget
+
+		return true
+	}, onChange)
+}
+
+// watchDir calls onChange when an event in the directory is accepted by the
+// filter, debouncing bursts of events
+func watchDir(
+	ctx context.Context,
+	dir string,
+	debounce time.Duration,
+	filter func(event fsnotify.Event) bool,
+	onChange func(),
+) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var timer <-chan time.Time
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Has(fsnotify.Chmod) || !filter(event) {
+					continue
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"dir":   dir,
+					"event": event.String(),
+				}).Debug("Watched directory changed")
+
+				timer = time.After(debounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				logrus.WithError(err).Error("File watcher error")
+			case <-timer:
+				timer = nil
+
+				onChange()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
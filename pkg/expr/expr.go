@@ -0,0 +1,300 @@
+// Package expr implements the filter expressions of custom metrics, e.g.
+//
+//	path == "/orders" && header["x-client"] =~ "ios|android" && status >= 500
+//
+// Fields resolve to lists of values, e.g. the tags of an operation, and a
+// comparison holds when any of the values satisfies it. != and !~ are the
+// negations of == and =~, so they hold when no value matches. Regexes match
+// whole values.
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Env resolves a field, with the key given in brackets if any, to its values
+type Env func(field string, key string) []string
+
+// Expr is a parsed expression
+type Expr struct {
+	root   node
+	fields []string
+}
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", next.value, next.pos)
+	}
+
+	return &Expr{root: root, fields: p.fields}, nil
+}
+
+// Fields returns the names of the fields referenced by the expression
+func (e *Expr) Fields() []string {
+	return e.fields
+}
+
+// Eval evaluates the expression against the fields resolved by env
+func (e *Expr) Eval(env Env) bool {
+	return e.root.eval(env)
+}
+
+type node interface {
+	eval(env Env) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(env Env) bool { return n.left.eval(env) && n.right.eval(env) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(env Env) bool { return n.left.eval(env) || n.right.eval(env) }
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(env Env) bool { return !n.operand.eval(env) }
+
+type field struct {
+	name string
+	key  string
+}
+
+// comparison compares the values of a field with a literal. A comparison
+// without an operator holds when the field has a non-empty value.
+type comparison struct {
+	field    field
+	operator string
+	literal  string
+	number   float64
+	regex    *regexp.Regexp
+}
+
+func (n comparison) eval(env Env) bool {
+	values := env(n.field.name, n.field.key)
+
+	switch n.operator {
+	case "!=":
+		return !n.any(values, "==")
+	case "!~":
+		return !n.any(values, "=~")
+	default:
+		return n.any(values, n.operator)
+	}
+}
+
+func (n comparison) any(values []string, operator string) bool {
+	for _, value := range values {
+		if n.matches(value, operator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n comparison) matches(value string, operator string) bool {
+	switch operator {
+	case "":
+		return value != ""
+	case "==":
+		return value == n.literal
+	case "=~":
+		return n.regex.MatchString(value)
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+
+	switch operator {
+	case "<":
+		return number < n.number
+	case "<=":
+		return number <= n.number
+	case ">":
+		return number > n.number
+	case ">=":
+		return number >= n.number
+	default:
+		return false
+	}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) accept(operator string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.value == operator {
+		p.pos++
+
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(operator string) error {
+	if !p.accept(operator) {
+		t := p.peek()
+
+		return fmt.Errorf("expected %q at %d", operator, t.pos)
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = andNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{operand}, nil
+	}
+
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, p.expect(")")
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		return nil, fmt.Errorf("expected a field at %d", t.pos)
+	}
+
+	n := comparison{field: field{name: t.value}}
+	p.fields = append(p.fields, t.value)
+
+	if p.accept("[") {
+		key := p.next()
+		if key.kind != tokenString {
+			return nil, fmt.Errorf("expected a quoted key at %d", key.pos)
+		}
+
+		n.field.key = key.value
+
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+
+	operator := p.peek()
+
+	switch operator.value {
+	case "==", "!=", "=~", "!~", "<", "<=", ">", ">=":
+		if operator.kind != tokenOperator {
+			return n, nil
+		}
+
+		p.pos++
+	default:
+		// A field on its own tests for a non-empty value
+		return n, nil
+	}
+
+	n.operator = operator.value
+
+	literal := p.next()
+	if literal.kind != tokenString && literal.kind != tokenNumber {
+		return nil, fmt.Errorf("expected a literal at %d", literal.pos)
+	}
+
+	n.literal = literal.value
+
+	switch n.operator {
+	case "=~", "!~":
+		regex, err := regexp.Compile("^(?:" + literal.value + ")$")
+		if err != nil {
+			return nil, err
+		}
+
+		n.regex = regex
+	case "<", "<=", ">", ">=":
+		number, err := strconv.ParseFloat(literal.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number at %d", literal.pos)
+		}
+
+		n.number = number
+	}
+
+	return n, nil
+}
@@ -0,0 +1,76 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	fields := map[string][]string{
+		"path":    {"/orders"},
+		"status":  {"503"},
+		"tag":     {"orders", "reports"},
+		"latency": {"120"},
+	}
+	headers := map[string]string{"x-client": "mobile"}
+
+	env := func(field string, key string) []string {
+		if field == "header" {
+			return []string{headers[key]}
+		}
+
+		return fields[field]
+	}
+
+	for _, tc := range []struct {
+		src      string
+		expected bool
+	}{
+		{`path == "/orders"`, true},
+		{`path != "/orders"`, false},
+		{`path == '/users'`, false},
+		{`header["x-client"] == "mobile"`, true},
+		{`header["x-client"] =~ "mob.*"`, true},
+		{`header["x-client"] =~ "mob"`, false},
+		{`header["x-client"] !~ "web|desktop"`, true},
+		{`tag == "reports"`, true},
+		{`tag != "reports"`, false},
+		{`status >= 500 && status < 600`, true},
+		{`latency > 200 || path == "/orders"`, true},
+		{`!(status >= 500)`, false},
+		{`header["x-debug"]`, false},
+		{`header["x-client"] && path == "/orders"`, true},
+		{`unknown == "x"`, false},
+	} {
+		e, err := Parse(tc.src)
+		assert.NoError(t, err, tc.src)
+		assert.Equal(t, tc.expected, e.Eval(env), tc.src)
+	}
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse(`path == "/orders" && header["x-client"] == "mobile"`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"path", "header"}, e.Fields())
+
+	e, err = Parse(`header["x-version"] =~ "v\d+"`)
+	assert.NoError(t, err)
+	assert.True(t, e.Eval(func(string, string) []string { return []string{"v2"} }))
+
+	for _, src := range []string{
+		`path ==`,
+		`path == "/orders" &&`,
+		`(path == "/orders"`,
+		`path == "/orders")`,
+		`"/orders" == path`,
+		`status > "high"`,
+		`path =~ "("`,
+		`path == "/orders`,
+		`header[x-client] == "mobile"`,
+		`path # "x"`,
+	} {
+		_, err := Parse(src)
+		assert.Error(t, err, src)
+	}
+}
@@ -0,0 +1,108 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// operators are ordered so that longer operators are matched first
+var operators = []string{"==", "!=", "=~", "!~", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(src); {
+		c := rune(src[pos])
+
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '"' || c == '\'':
+			value, end, err := lexString(src, pos)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{kind: tokenString, value: value, pos: pos})
+			pos = end
+		case unicode.IsDigit(c) || c == '-' || c == '.':
+			end := pos + 1
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenNumber, value: src[pos:end], pos: pos})
+			pos = end
+		case unicode.IsLetter(c) || c == '_':
+			end := pos + 1
+			for end < len(src) && (unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '_') {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenIdent, value: src[pos:end], pos: pos})
+			pos = end
+		default:
+			operator := ""
+			for _, op := range operators {
+				if strings.HasPrefix(src[pos:], op) {
+					operator = op
+
+					break
+				}
+			}
+
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, pos)
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, value: operator, pos: pos})
+			pos += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
+
+// lexString reads a quoted string starting at pos, returning its unescaped
+// value and the position after the closing quote
+func lexString(src string, pos int) (string, int, error) {
+	quote := src[pos]
+
+	var value strings.Builder
+
+	for i := pos + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			// Only quotes and backslashes are escaped, so regexes like
+			// "\d+" can be written as is
+			if i+1 < len(src) && (src[i+1] == quote || src[i+1] == '\\') {
+				i++
+			}
+
+			value.WriteByte(src[i])
+		case quote:
+			return value.String(), i + 1, nil
+		default:
+			value.WriteByte(src[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at %d", pos)
+}
@@ -0,0 +1,99 @@
+package kong
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// AdminClient reads entities from the Kong Admin API
+type AdminClient struct {
+	URL    string
+	Client *http.Client
+
+	// Token, when set, is sent in the Kong-Admin-Token header
+	Token string
+}
+
+type Service struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type servicesPage struct {
+	Data []Service `json:"data"`
+	Next *string   `json:"next"`
+}
+
+// ListServices lists all services, following the pagination of the Admin API
+func (c *AdminClient) ListServices(ctx context.Context) ([]Service, error) {
+	var services []Service
+
+	next := "/services"
+	for next != "" {
+		var page servicesPage
+		if err := c.get(ctx, next, &page); err != nil {
+			return nil, err
+		}
+
+		services = append(services, page.Data...)
+
+		next = ""
+		if page.Next != nil {
+			next = *page.Next
+		}
+	}
+
+	return services, nil
+}
+
+func (c *AdminClient) get(ctx context.Context, path string, v any) error {
+	base, err := url.Parse(strings.TrimSuffix(c.URL, "/") + "/")
+	if err != nil {
+		return err
+	}
+
+	// The next page is given relative to the root of the Admin API
+	ref, err := url.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.ResolveReference(ref).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if c.Token != "" {
+		req.Header.Set("Kong-Admin-Token", c.Token)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s listing %s", resp.Status, path)
+	}
+
+	return jsoniter.NewDecoder(resp.Body).Decode(v)
+}
+
+// TagValue returns the value of the first tag with the given prefix
+func (s *Service) TagValue(prefix string) (string, bool) {
+	for _, tag := range s.Tags {
+		if value, ok := strings.CutPrefix(tag, prefix); ok {
+			return value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,59 @@
+package kong
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestAdminClient_ListServices(t *testing.T) {
+	ctx := context.Background()
+
+	// A stand-in for the Admin API serving two pages of services
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Kong-Admin-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.URL.Query().Get("offset") {
+		case "":
+			w.Write([]byte(`{
+				"data": [{"id": "1", "name": "billing", "tags": ["team-a", "openapi-url:http://billing/openapi.yaml"]}],
+				"next": "/services?offset=page2"
+			}`))
+		case "page2":
+			w.Write([]byte(`{
+				"data": [{"id": "2", "name": "users", "tags": null}],
+				"next": null
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &AdminClient{
+		URL:    server.URL,
+		Client: server.Client(),
+		Token:  "secret",
+	}
+
+	services, err := client.ListServices(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, services, 2)
+
+	specURL, ok := services[0].TagValue("openapi-url:")
+	assert.True(t, ok)
+	assert.Equal(t, "http://billing/openapi.yaml", specURL)
+
+	_, ok = services[1].TagValue("openapi-url:")
+	assert.False(t, ok)
+
+	client.Token = ""
+
+	_, err = client.ListServices(ctx)
+	assert.Error(t, err)
+}
@@ -7,23 +7,44 @@ import (
 )
 
 type Log struct {
-	Request   Request   `json:"request"`
-	Response  Response  `json:"response"`
-	Latencies Latencies `json:"latencies"`
+	Request       Request   `json:"request"`
+	Response      Response  `json:"response"`
+	Latencies     Latencies `json:"latencies"`
+	Service       Service   `json:"service"`
+	Route         Route     `json:"route"`
+	Consumer      Consumer  `json:"consumer"`
+	Workspace     string    `json:"workspace"`
+	WorkspaceName string    `json:"workspace_name"`
+	UpstreamURI   string    `json:"upstream_uri"`
+}
+
+type Route struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Consumer struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	CustomID string `json:"custom_id"`
 }
 
 type Latencies struct {
 	Request int `json:"request"`
+	Kong    int `json:"kong"`
+	Proxy   int `json:"proxy"`
 }
 
 type Request struct {
 	URI     string            `json:"uri"`
 	Headers map[string]string `json:"headers"`
 	Method  string            `json:"method"`
+	Size    int               `json:"size"`
 }
 
 type Response struct {
 	Status int `json:"status"`
+	Size   int `json:"size"`
 }
 
 func ParseLog(body io.Reader) (*Log, error) {
@@ -0,0 +1,32 @@
+package kong
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestParseLog(t *testing.T) {
+	// An abbreviated log of the http-log plugin
+	log, err := ParseLog(strings.NewReader(`{
+		"request": {"uri": "/api/v1/users", "method": "GET", "headers": {"host": "api.example.com"}},
+		"response": {"status": 200},
+		"latencies": {"request": 12},
+		"service": {"id": "s-1", "name": "users"},
+		"route": {"id": "r-1", "name": "users-v1"},
+		"consumer": {"id": "c-1", "username": "mobile-app", "custom_id": "team-42"},
+		"workspace": "w-1",
+		"workspace_name": "default",
+		"upstream_uri": "/users"
+	}`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "GET", log.Request.Method)
+	assert.Equal(t, 12, log.Latencies.Request)
+	assert.Equal(t, "users", log.Service.Name)
+	assert.Equal(t, "users-v1", log.Route.Name)
+	assert.Equal(t, Consumer{ID: "c-1", Username: "mobile-app", CustomID: "team-42"}, log.Consumer)
+	assert.Equal(t, "default", log.WorkspaceName)
+	assert.Equal(t, "/users", log.UpstreamURI)
+}
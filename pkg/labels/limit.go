@@ -0,0 +1,111 @@
+package labels
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Other is the value that label values beyond a limit collapse into
+const Other = "__other__"
+
+// Rewrite replaces label values fully matching Regex with Replacement, which
+// may reference capture groups, e.g. "$1"
+type Rewrite struct {
+	Regex       *regexp.Regexp
+	Replacement string
+}
+
+// NewRewrite compiles a rewrite rule. The regex is anchored at both ends.
+func NewRewrite(regex string, replacement string) (Rewrite, error) {
+	compiled, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return Rewrite{}, err
+	}
+
+	return Rewrite{Regex: compiled, Replacement: replacement}, nil
+}
+
+// Limit bounds the values of a label. Values are rewritten by the first
+// matching rule, then collapsed into Other when not allowed, or when the
+// maximum number of distinct values has been reached.
+type Limit struct {
+	MaxValues int
+	Allow     map[string]struct{}
+	Rewrites  []Rewrite
+
+	mu     sync.Mutex
+	values map[string]struct{}
+}
+
+// NewLimit creates a limit. A max of 0 allows any number of values, and an
+// empty allow-list allows any value.
+func NewLimit(maxValues int, allow []string, rewrites []Rewrite) *Limit {
+	limit := &Limit{
+		MaxValues: maxValues,
+		Rewrites:  rewrites,
+		values:    map[string]struct{}{},
+	}
+
+	if len(allow) > 0 {
+		limit.Allow = map[string]struct{}{}
+
+		for _, value := range allow {
+			limit.Allow[value] = struct{}{}
+		}
+	}
+
+	return limit
+}
+
+// Value returns the value to use for the label, and whether it overflowed
+// into Other
+func (l *Limit) Value(value string) (string, bool) {
+	for _, rewrite := range l.Rewrites {
+		match := rewrite.Regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			continue
+		}
+
+		value = string(rewrite.Regex.ExpandString(nil, rewrite.Replacement, value, match))
+
+		break
+	}
+
+	if l.Allow != nil {
+		if _, ok := l.Allow[value]; !ok {
+			return Other, true
+		}
+	}
+
+	if l.MaxValues == 0 {
+		return value, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.values[value]; ok {
+		return value, false
+	}
+
+	if len(l.values) >= l.MaxValues {
+		return Other, true
+	}
+
+	l.values[value] = struct{}{}
+
+	return value, false
+}
+
+// Retain forgets the values not in live, e.g. the values of expired series,
+// making room for new values
+func (l *Limit) Retain(live map[string]struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for value := range l.values {
+		if _, ok := live[value]; !ok {
+			delete(l.values, value)
+		}
+	}
+}
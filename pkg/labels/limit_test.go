@@ -0,0 +1,85 @@
+package labels
+
+import (
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestLimit_Value(t *testing.T) {
+	t.Run("max values", func(t *testing.T) {
+		limit := NewLimit(2, nil, nil)
+
+		for _, tc := range []struct {
+			value      string
+			expected   string
+			overflowed bool
+		}{
+			{"a", "a", false},
+			{"b", "b", false},
+			{"c", Other, true},
+			{"a", "a", false},
+		} {
+			value, overflowed := limit.Value(tc.value)
+			assert.Equal(t, tc.expected, value)
+			assert.Equal(t, tc.overflowed, overflowed)
+		}
+	})
+
+	t.Run("retained values", func(t *testing.T) {
+		limit := NewLimit(1, nil, nil)
+
+		value, _ := limit.Value("a")
+		assert.Equal(t, "a", value)
+
+		value, _ = limit.Value("b")
+		assert.Equal(t, Other, value)
+
+		limit.Retain(map[string]struct{}{})
+
+		value, _ = limit.Value("b")
+		assert.Equal(t, "b", value)
+	})
+
+	t.Run("allow-list", func(t *testing.T) {
+		limit := NewLimit(0, []string{"ios", "android"}, nil)
+
+		value, overflowed := limit.Value("ios")
+		assert.Equal(t, "ios", value)
+		assert.False(t, overflowed)
+
+		value, overflowed = limit.Value("web")
+		assert.Equal(t, Other, value)
+		assert.True(t, overflowed)
+	})
+
+	t.Run("rewrites", func(t *testing.T) {
+		version, err := NewRewrite(`(\w+)/(\d+)\..*`, "$1/$2")
+		assert.NoError(t, err)
+
+		curl, err := NewRewrite(`curl.*`, "curl")
+		assert.NoError(t, err)
+
+		limit := NewLimit(0, nil, []Rewrite{version, curl})
+
+		value, _ := limit.Value("Mozilla/5.0 (X11; Linux x86_64)")
+		assert.Equal(t, "Mozilla/5", value)
+
+		value, _ = limit.Value("curl 8.4.0")
+		assert.Equal(t, "curl", value)
+
+		value, _ = limit.Value("unknown")
+		assert.Equal(t, "unknown", value)
+	})
+
+	t.Run("rewrites are applied before the allow-list", func(t *testing.T) {
+		rewrite, err := NewRewrite(`(ios|android)-.*`, "$1")
+		assert.NoError(t, err)
+
+		limit := NewLimit(0, []string{"ios", "android"}, []Rewrite{rewrite})
+
+		value, overflowed := limit.Value("ios-17.1")
+		assert.Equal(t, "ios", value)
+		assert.False(t, overflowed)
+	})
+}
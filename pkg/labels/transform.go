@@ -0,0 +1,129 @@
+package labels
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Transform turns a raw value, e.g. of a header, into a label value
+type Transform func(value string) string
+
+// Chain applies the transforms in order
+func Chain(transforms ...Transform) Transform {
+	return func(value string) string {
+		for _, transform := range transforms {
+			value = transform(value)
+		}
+
+		return value
+	}
+}
+
+// HMAC replaces values with their hex encoded HMAC-SHA256 under the key,
+// truncated to length characters unless length is 0. Empty values are kept,
+// so a missing header stays distinguishable.
+func HMAC(key []byte, length int) Transform {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+
+		sum := hex.EncodeToString(mac.Sum(nil))
+		if length > 0 && length < len(sum) {
+			sum = sum[:length]
+		}
+
+		return sum
+	}
+}
+
+// Prefix truncates values to their first length characters
+func Prefix(length int) Transform {
+	return func(value string) string {
+		runes := []rune(value)
+		if len(runes) <= length {
+			return value
+		}
+
+		return string(runes[:length])
+	}
+}
+
+// Capture replaces values with the first capture group of the first match of
+// the regex, or the whole match when it has no groups. Values not matching
+// become empty.
+func Capture(regex *regexp.Regexp) Transform {
+	return func(value string) string {
+		match := regex.FindStringSubmatch(value)
+		if match == nil {
+			return ""
+		}
+
+		if len(match) > 1 {
+			return match[1]
+		}
+
+		return match[0]
+	}
+}
+
+// JWTClaim replaces values with a claim of the JSON web token they contain,
+// optionally prefixed with "Bearer ". The signature is not verified, so the
+// claim must not be trusted beyond labelling. Values without a valid token or
+// claim become empty.
+func JWTClaim(claim string) Transform {
+	return func(value string) string {
+		token := strings.TrimSpace(value)
+		if scheme, rest, ok := strings.Cut(token, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(rest)
+		}
+
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			return ""
+		}
+
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return ""
+		}
+
+		var claims map[string]any
+		if err := jsoniter.Unmarshal(payload, &claims); err != nil {
+			return ""
+		}
+
+		switch v := claims[claim].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		default:
+			return ""
+		}
+	}
+}
+
+// Lookup maps values through the table, e.g. API keys to team names. Values
+// not in the table become fallback.
+func Lookup(table map[string]string, fallback string) Transform {
+	return func(value string) string {
+		if mapped, ok := table[value]; ok {
+			return mapped
+		}
+
+		return fallback
+	}
+}
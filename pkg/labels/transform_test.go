@@ -0,0 +1,60 @@
+package labels
+
+import (
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestHMAC(t *testing.T) {
+	transform := HMAC([]byte("secret"), 12)
+
+	hashed := transform("api-key-1")
+	assert.Len(t, hashed, 12)
+	assert.NotContains(t, hashed, "api-key")
+	assert.Equal(t, hashed, transform("api-key-1"))
+	assert.NotEqual(t, hashed, transform("api-key-2"))
+	assert.NotEqual(t, hashed, HMAC([]byte("other"), 12)("api-key-1"))
+	assert.Equal(t, "", transform(""))
+}
+
+func TestPrefix(t *testing.T) {
+	assert.Equal(t, "sk_li", Prefix(5)("sk_live_1234"))
+	assert.Equal(t, "abc", Prefix(5)("abc"))
+}
+
+func TestCapture(t *testing.T) {
+	transform := Capture(regexp.MustCompile(`tenant=(\w+)`))
+
+	assert.Equal(t, "acme", transform("region=eu; tenant=acme"))
+	assert.Equal(t, "", transform("region=eu"))
+	assert.Equal(t, "eu", Capture(regexp.MustCompile(`eu|us`))("region=eu"))
+}
+
+func TestJWTClaim(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1","client_id":"mobile","iat":1700000000}`))
+	token := "eyJhbGciOiJIUzI1NiJ9." + payload + ".signature"
+
+	assert.Equal(t, "user-1", JWTClaim("sub")("Bearer "+token))
+	assert.Equal(t, "mobile", JWTClaim("client_id")(token))
+	assert.Equal(t, "1700000000", JWTClaim("iat")(token))
+	assert.Equal(t, "", JWTClaim("scope")(token))
+	assert.Equal(t, "", JWTClaim("sub")("Bearer opaque-token"))
+	assert.Equal(t, "", JWTClaim("sub")("Basic dXNlcjpwYXNz"))
+}
+
+func TestLookup(t *testing.T) {
+	transform := Lookup(map[string]string{"key-1": "payments", "key-2": "search"}, Other)
+
+	assert.Equal(t, "payments", transform("key-1"))
+	assert.Equal(t, Other, transform("key-3"))
+}
+
+func TestChain(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user-1"}`))
+	transform := Chain(JWTClaim("sub"), HMAC([]byte("secret"), 8))
+
+	assert.Equal(t, HMAC([]byte("secret"), 8)("user-1"), transform("Bearer h."+payload+".s"))
+}
@@ -0,0 +1,153 @@
+package series
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Vec is a metric vector whose series can be deleted
+type Vec interface {
+	Delete(labels prometheus.Labels) bool
+}
+
+// Tracker records when the series of metric vectors were last updated, so
+// idle series can be deleted
+type Tracker struct {
+	mu     sync.Mutex
+	series map[key]*entry
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type key struct {
+	vec    Vec
+	labels string
+}
+
+type entry struct {
+	labels  prometheus.Labels
+	updated time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		series: map[key]*entry{},
+		now:    time.Now,
+	}
+}
+
+// Touch records an update of the series of the vector with the labels
+func (t *Tracker) Touch(vec Vec, labels prometheus.Labels) {
+	k := key{vec: vec, labels: labelsKey(labels)}
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.series[k]; ok {
+		e.updated = now
+
+		return
+	}
+
+	copied := make(prometheus.Labels, len(labels))
+	for name, value := range labels {
+		copied[name] = value
+	}
+
+	t.series[k] = &entry{labels: copied, updated: now}
+}
+
+// Expire deletes the series not updated within the ttl, returning how many
+// were deleted
+func (t *Tracker) Expire(ttl time.Duration) int {
+	deadline := t.now().Add(-ttl)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expired := 0
+	for k, e := range t.series {
+		if e.updated.After(deadline) {
+			continue
+		}
+
+		delete(t.series, k)
+
+		if k.vec.Delete(e.labels) {
+			expired++
+		}
+	}
+
+	return expired
+}
+
+// Forget stops tracking the series of all vectors having all of the labels,
+// e.g. after deleting them with DeletePartialMatch, returning how many were
+// forgotten
+func (t *Tracker) Forget(labels prometheus.Labels) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	forgotten := 0
+	for k, e := range t.series {
+		if !hasLabels(e.labels, labels) {
+			continue
+		}
+
+		delete(t.series, k)
+		forgotten++
+	}
+
+	return forgotten
+}
+
+func hasLabels(labels prometheus.Labels, subset prometheus.Labels) bool {
+	for name, value := range subset {
+		if labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Values returns the distinct values of a label across the tracked series
+func (t *Tracker) Values(label string) map[string]struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	values := map[string]struct{}{}
+	for _, e := range t.series {
+		if value, ok := e.labels[label]; ok {
+			values[value] = struct{}{}
+		}
+	}
+
+	return values
+}
+
+// labelsKey serializes labels in a stable order
+func labelsKey(labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(0)
+		b.WriteString(labels[name])
+		b.WriteByte(0)
+	}
+
+	return b.String()
+}
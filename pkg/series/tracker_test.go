@@ -0,0 +1,70 @@
+package series
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tj/assert"
+)
+
+func TestTracker_Expire(t *testing.T) {
+	now := time.Now()
+
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"consumer"})
+
+	for _, consumer := range []string{"a", "b"} {
+		labels := prometheus.Labels{"consumer": consumer}
+
+		vec.With(labels).Inc()
+		tracker.Touch(vec, labels)
+	}
+
+	// Only b is updated after a minute
+	now = now.Add(time.Minute)
+
+	vec.With(prometheus.Labels{"consumer": "b"}).Inc()
+	tracker.Touch(vec, prometheus.Labels{"consumer": "b"})
+
+	now = now.Add(30 * time.Second)
+
+	assert.Equal(t, 1, tracker.Expire(time.Minute))
+	assert.Equal(t, 1, testutil.CollectAndCount(vec))
+	assert.Equal(t, map[string]struct{}{"b": {}}, tracker.Values("consumer"))
+
+	now = now.Add(time.Minute)
+
+	assert.Equal(t, 1, tracker.Expire(time.Minute))
+	assert.Equal(t, 0, testutil.CollectAndCount(vec))
+	assert.Len(t, tracker.Values("consumer"), 0)
+}
+
+func TestTracker_Forget(t *testing.T) {
+	tracker := NewTracker()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"path", "consumer"})
+	errors := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "errors_total"}, []string{"path", "consumer"})
+
+	for _, series := range []struct {
+		vec    *prometheus.CounterVec
+		labels prometheus.Labels
+	}{
+		{requests, prometheus.Labels{"path": "/users", "consumer": "a"}},
+		{errors, prometheus.Labels{"path": "/users", "consumer": "a"}},
+		{requests, prometheus.Labels{"path": "/orders", "consumer": "b"}},
+	} {
+		series.vec.With(series.labels).Inc()
+		tracker.Touch(series.vec, series.labels)
+	}
+
+	assert.Equal(t, 2, tracker.Forget(prometheus.Labels{"path": "/users"}))
+	assert.Equal(t, map[string]struct{}{"b": {}}, tracker.Values("consumer"))
+
+	// Only the remaining series is expired
+	assert.Equal(t, 1, tracker.Expire(-time.Minute))
+	assert.Equal(t, 2, testutil.CollectAndCount(requests)+testutil.CollectAndCount(errors))
+}
@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// relativeAccuracy is the relative error of the quantiles estimated by a window
+const relativeAccuracy = 0.01
+
+// gamma is the ratio between the bounds of consecutive latency buckets
+var gamma = (1 + relativeAccuracy) / (1 - relativeAccuracy)
+
+// Window aggregates observations of requests over a sliding time window. The
+// window is split into slots, which expire one at a time. Latencies are kept
+// in logarithmic buckets, so quantiles are estimated within 1% of the exact
+// value in bounded memory.
+type Window struct {
+	size     time.Duration
+	slotSize time.Duration
+	slots    []slot
+	start    time.Time
+
+	mu sync.Mutex
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type slot struct {
+	start     time.Time
+	requests  uint64
+	errors    uint64
+	latencies map[int]uint64
+}
+
+// Snapshot is the aggregate of the observations in a window
+type Snapshot struct {
+	Requests uint64
+	Errors   uint64
+
+	// RequestRate and ErrorRate are per second, over the window or the time
+	// since the start of the window if shorter
+	RequestRate float64
+	ErrorRate   float64
+	ErrorRatio  float64
+
+	// Quantiles are the estimated latencies by quantile
+	Quantiles map[float64]float64
+}
+
+// NewWindow creates a window of the given size, split into the given number
+// of slots. Rates are computed over the time since start while it is shorter
+// than the window, so they are not underestimated after a restart.
+func NewWindow(size time.Duration, slots int, start time.Time) *Window {
+	return newWindow(size, slots, start, time.Now)
+}
+
+func newWindow(size time.Duration, slots int, start time.Time, now func() time.Time) *Window {
+	return &Window{
+		size:     size,
+		slotSize: size / time.Duration(slots),
+		slots:    make([]slot, slots),
+		start:    start,
+		now:      now,
+	}
+}
+
+// Observe records a request with its latency
+func (w *Window) Observe(latency float64, isError bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := w.currentSlot()
+
+	s.requests++
+	if isError {
+		s.errors++
+	}
+
+	if s.latencies == nil {
+		s.latencies = map[int]uint64{}
+	}
+
+	s.latencies[bucketIndex(latency)]++
+}
+
+// currentSlot returns the slot of the current time, resetting it when it
+// held an earlier period
+func (w *Window) currentSlot() *slot {
+	now := w.now()
+	start := now.Truncate(w.slotSize)
+	s := &w.slots[int(start.UnixNano()/int64(w.slotSize))%len(w.slots)]
+
+	if !s.start.Equal(start) {
+		*s = slot{start: start}
+	}
+
+	return s
+}
+
+// Snapshot aggregates the observations within the window
+func (w *Window) Snapshot(quantiles []float64) Snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	oldest := now.Add(-w.size)
+
+	snapshot := Snapshot{Quantiles: map[float64]float64{}}
+	latencies := map[int]uint64{}
+
+	for _, s := range w.slots {
+		if !s.start.After(oldest) {
+			continue
+		}
+
+		snapshot.Requests += s.requests
+		snapshot.Errors += s.errors
+
+		for index, count := range s.latencies {
+			latencies[index] += count
+		}
+	}
+
+	if snapshot.Requests == 0 {
+		return snapshot
+	}
+
+	elapsed := min(w.size, now.Sub(w.start)).Seconds()
+	if elapsed > 0 {
+		snapshot.RequestRate = float64(snapshot.Requests) / elapsed
+		snapshot.ErrorRate = float64(snapshot.Errors) / elapsed
+	}
+
+	snapshot.ErrorRatio = float64(snapshot.Errors) / float64(snapshot.Requests)
+
+	indexes := make([]int, 0, len(latencies))
+	for index := range latencies {
+		indexes = append(indexes, index)
+	}
+
+	sort.Ints(indexes)
+
+	for _, q := range quantiles {
+		// The rank of the quantile, counted from 1
+		rank := uint64(math.Ceil(q * float64(snapshot.Requests)))
+		if rank == 0 {
+			rank = 1
+		}
+
+		var seen uint64
+		for _, index := range indexes {
+			seen += latencies[index]
+			if seen >= rank {
+				snapshot.Quantiles[q] = bucketValue(index)
+
+				break
+			}
+		}
+	}
+
+	return snapshot
+}
+
+// bucketIndex returns the index of the logarithmic bucket of a latency.
+// Latencies below 1 share the bucket 0.
+func bucketIndex(latency float64) int {
+	if latency < 1 {
+		return 0
+	}
+
+	return int(math.Ceil(math.Log(latency)/math.Log(gamma))) + 1
+}
+
+// bucketValue returns the estimate of the latencies in a bucket, within the
+// relative accuracy of all of them
+func bucketValue(index int) float64 {
+	if index == 0 {
+		return 0
+	}
+
+	return 2 * math.Pow(gamma, float64(index-1)) / (gamma + 1)
+}
@@ -0,0 +1,45 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestWindow_Snapshot(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	window := newWindow(time.Minute, 6, now, func() time.Time { return now })
+
+	// 1..100ms, every tenth an error
+	for i := 1; i <= 100; i++ {
+		window.Observe(float64(i), i%10 == 0)
+	}
+
+	now = now.Add(30 * time.Second)
+
+	snapshot := window.Snapshot([]float64{0.5, 0.99})
+	assert.Equal(t, uint64(100), snapshot.Requests)
+	assert.Equal(t, uint64(10), snapshot.Errors)
+	assert.InDelta(t, 100.0/30, snapshot.RequestRate, 0.001)
+	assert.InDelta(t, 10.0/30, snapshot.ErrorRate, 0.001)
+	assert.InDelta(t, 0.1, snapshot.ErrorRatio, 0.001)
+	assert.InEpsilon(t, 50, snapshot.Quantiles[0.5], 0.01)
+	assert.InEpsilon(t, 99, snapshot.Quantiles[0.99], 0.01)
+
+	// The slot of the observations expires after the window
+	now = now.Add(40 * time.Second)
+
+	snapshot = window.Snapshot([]float64{0.5})
+	assert.Equal(t, uint64(0), snapshot.Requests)
+	assert.Len(t, snapshot.Quantiles, 0)
+
+	// Reused slots start empty
+	window.Observe(0, false)
+
+	snapshot = window.Snapshot([]float64{0.5})
+	assert.Equal(t, uint64(1), snapshot.Requests)
+	assert.Equal(t, 0.0, snapshot.Quantiles[0.5])
+	assert.InDelta(t, 1.0/60, snapshot.RequestRate, 0.001)
+}
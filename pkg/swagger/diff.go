@@ -0,0 +1,112 @@
+package swagger
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pb33f/libopenapi/orderedmap"
+)
+
+// Kinds of operation changes
+const (
+	OperationAdded   = "added"
+	OperationRemoved = "removed"
+	OperationChanged = "changed"
+)
+
+// Operation identifies an operation of a specification
+type Operation struct {
+	Method      string
+	Path        string
+	OperationID string
+
+	// hash of the operation definition, used to detect changes
+	hash [32]byte
+}
+
+// OperationChange is an operation added, removed or changed between two
+// versions of a specification
+type OperationChange struct {
+	Kind      string
+	Operation Operation
+}
+
+// Operations lists the operations of the specification ordered by path and
+// method. A nil specification has no operations.
+func (s *Specification) Operations(ctx context.Context) []Operation {
+	var ops []Operation
+
+	if s == nil || s.Document == nil || s.Document.Model.Paths == nil {
+		return ops
+	}
+
+	for pathItem := range orderedmap.Iterate(ctx, s.Document.Model.Paths.PathItems) {
+		for _, method := range operations {
+			operation := getOperation(pathItem.Value(), method)
+			if operation == nil {
+				continue
+			}
+
+			op := Operation{
+				Method:      method,
+				Path:        pathItem.Key(),
+				OperationID: operation.OperationId,
+			}
+
+			if low := operation.GoLow(); low != nil {
+				op.hash = low.Hash()
+			}
+
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+
+		return ops[i].Method < ops[j].Method
+	})
+
+	return ops
+}
+
+// DiffOperations returns the operations added, removed or changed from the old
+// to the new specification, identified by method and path. An operation is
+// changed when its operationId or any part of its definition changed. A nil
+// specification is empty, so all operations of a new or removed
+// specification are added or removed.
+func DiffOperations(ctx context.Context, oldSpec *Specification, newSpec *Specification) []OperationChange {
+	type key struct{ method, path string }
+
+	oldOps := map[key]Operation{}
+	for _, op := range oldSpec.Operations(ctx) {
+		oldOps[key{op.Method, op.Path}] = op
+	}
+
+	var changes []OperationChange
+
+	for _, op := range newSpec.Operations(ctx) {
+		k := key{op.Method, op.Path}
+
+		oldOp, ok := oldOps[k]
+		delete(oldOps, k)
+
+		switch {
+		case !ok:
+			changes = append(changes, OperationChange{Kind: OperationAdded, Operation: op})
+		case oldOp.OperationID != op.OperationID || oldOp.hash != op.hash:
+			changes = append(changes, OperationChange{Kind: OperationChanged, Operation: op})
+		}
+	}
+
+	// The remaining old operations were removed
+	for _, op := range oldSpec.Operations(ctx) {
+		if _, ok := oldOps[key{op.Method, op.Path}]; ok {
+			changes = append(changes, OperationChange{Kind: OperationRemoved, Operation: op})
+		}
+	}
+
+	return changes
+}
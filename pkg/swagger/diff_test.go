@@ -0,0 +1,125 @@
+package swagger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pb33f/libopenapi/datamodel"
+	"github.com/tj/assert"
+)
+
+const diffSpecV1 = `
+openapi: 3.0.0
+info:
+  title: Diff
+  version: 1.0.0
+paths:
+  /users:
+    get:
+      operationId: listUsers
+      responses:
+        '200':
+          description: A list of users
+    post:
+      operationId: createUser
+      responses:
+        '200':
+          description: Created a user
+  /users/{userId}:
+    get:
+      operationId: getUser
+      parameters:
+        - name: userId
+          in: path
+          required: true
+          schema:
+            type: integer
+      responses:
+        '200':
+          description: A user
+`
+
+const diffSpecV2 = `
+openapi: 3.0.0
+info:
+  title: Diff
+  version: 2.0.0
+paths:
+  /users:
+    get:
+      operationId: listAllUsers
+      responses:
+        '200':
+          description: A list of users
+  /users/{userId}:
+    get:
+      operationId: getUser
+      parameters:
+        - name: userId
+          in: path
+          required: true
+          schema:
+            type: string
+      responses:
+        '200':
+          description: A user
+    delete:
+      operationId: deleteUser
+      parameters:
+        - name: userId
+          in: path
+          required: true
+          schema:
+            type: string
+      responses:
+        '204':
+          description: Deleted a user
+`
+
+func TestDiffOperations(t *testing.T) {
+	ctx := context.Background()
+
+	v1, err := newSpecification(ctx, []byte(diffSpecV1), datamodel.NewDocumentConfiguration())
+	assert.NoError(t, err)
+
+	v2, err := newSpecification(ctx, []byte(diffSpecV2), datamodel.NewDocumentConfiguration())
+	assert.NoError(t, err)
+
+	assert.Empty(t, DiffOperations(ctx, v1, v1))
+
+	changes := DiffOperations(ctx, v1, v2)
+
+	type change struct{ kind, method, path, operationID string }
+
+	actual := []change{}
+	for _, c := range changes {
+		actual = append(actual, change{c.Kind, c.Operation.Method, c.Operation.Path, c.Operation.OperationID})
+	}
+
+	assert.Equal(t, []change{
+		// operationId changed
+		{OperationChanged, "GET", "/users", "listAllUsers"},
+		{OperationAdded, "DELETE", "/users/{userId}", "deleteUser"},
+		// parameter type changed
+		{OperationChanged, "GET", "/users/{userId}", "getUser"},
+		{OperationRemoved, "POST", "/users", "createUser"},
+	}, actual)
+}
+
+func TestDiffOperations_Nil(t *testing.T) {
+	ctx := context.Background()
+
+	v1, err := newSpecification(ctx, []byte(diffSpecV1), datamodel.NewDocumentConfiguration())
+	assert.NoError(t, err)
+
+	for _, change := range DiffOperations(ctx, nil, v1) {
+		assert.Equal(t, OperationAdded, change.Kind)
+	}
+
+	for _, change := range DiffOperations(ctx, v1, nil) {
+		assert.Equal(t, OperationRemoved, change.Kind)
+	}
+
+	assert.Len(t, DiffOperations(ctx, nil, v1), len(v1.Operations(ctx)))
+	assert.Len(t, DiffOperations(ctx, v1, nil), len(v1.Operations(ctx)))
+}
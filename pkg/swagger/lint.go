@@ -0,0 +1,95 @@
+package swagger
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pb33f/libopenapi"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+)
+
+// Kinds of specification warnings
+const (
+	WarningUndeclaredPathParameter = "undeclared_path_parameter"
+	WarningDuplicateOperationID    = "duplicate_operation_id"
+	WarningConflictingPathTemplate = "conflicting_path_template"
+	WarningUnsupportedParamStyle   = "unsupported_parameter_style"
+	WarningInvalidSLO              = "invalid_slo"
+)
+
+// Warning is a problem found in a specification that does not prevent it from
+// loading. Paths with problems are matched in a degraded mode, e.g. with a
+// wildcard regex for an undeclared path parameter.
+type Warning struct {
+	Kind    string
+	Method  string
+	Path    string
+	Message string
+}
+
+var templateParamRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+func (s *Specification) warn(kind string, method string, path string, format string, args ...any) {
+	s.Warnings = append(s.Warnings, Warning{
+		Kind:    kind,
+		Method:  method,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lint collects warnings about the operations and paths of the document
+func (s *Specification) lint(ctx context.Context, docModel *libopenapi.DocumentModel[v3.Document]) {
+	operationIDs := map[string]string{}
+	templates := map[string]string{}
+
+	for pathItem := range orderedmap.Iterate(ctx, docModel.Model.Paths.PathItems) {
+		path := pathItem.Key()
+
+		// Paths only differing in parameter names match the same requests
+		template := templateParamRegex.ReplaceAllString(path, "{}")
+		if other, ok := templates[template]; ok {
+			s.warn(WarningConflictingPathTemplate, "", path, "path conflicts with %s", other)
+		} else {
+			templates[template] = path
+		}
+
+		for _, method := range operations {
+			operation := getOperation(pathItem.Value(), method)
+			if operation == nil {
+				continue
+			}
+
+			if id := operation.OperationId; id != "" {
+				if other, ok := operationIDs[id]; ok {
+					s.warn(WarningDuplicateOperationID, method, path, "operationId %s is also used by %s", id, other)
+				} else {
+					operationIDs[id] = method + " " + path
+				}
+			}
+
+			for _, param := range getPathParametersForOperationStr(method, pathItem.Value()) {
+				if param.In != "path" {
+					continue
+				}
+
+				if param.Style != "" && param.Style != "simple" {
+					s.warn(WarningUnsupportedParamStyle, method, path,
+						"parameter %s has unsupported style %s, matching any value", param.Name, param.Style)
+				}
+
+				if param.Schema == nil {
+					s.warn(WarningUnsupportedParamStyle, method, path,
+						"parameter %s has no schema, matching any value", param.Name)
+				}
+			}
+		}
+	}
+}
+
+func isTemplateParam(part string) bool {
+	return strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+}
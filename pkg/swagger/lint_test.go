@@ -0,0 +1,76 @@
+package swagger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pb33f/libopenapi/datamodel"
+	"github.com/tj/assert"
+)
+
+const lintSpec = `
+openapi: 3.0.0
+info:
+  title: Lint
+  version: 1.0.0
+paths:
+  /users/{userId}:
+    get:
+      operationId: getUser
+      responses:
+        '200':
+          description: A user
+  /users/{id}:
+    delete:
+      operationId: getUser
+      parameters:
+        - name: id
+          in: path
+          required: true
+          style: label
+          schema:
+            type: integer
+      responses:
+        '204':
+          description: Deleted a user
+  /orders/{orderId}:
+    get:
+      operationId: getOrder
+      parameters:
+        - name: orderId
+          in: path
+          required: true
+          style: label
+          schema:
+            type: integer
+      responses:
+        '200':
+          description: An order
+`
+
+func TestSpecification_Warnings(t *testing.T) {
+	ctx := context.Background()
+
+	spec, err := newSpecification(ctx, []byte(lintSpec), datamodel.NewDocumentConfiguration())
+	assert.NoError(t, err)
+
+	kinds := map[string]int{}
+	for _, warning := range spec.Warnings {
+		kinds[warning.Kind]++
+	}
+
+	assert.Equal(t, map[string]int{
+		WarningUndeclaredPathParameter: 1,
+		WarningDuplicateOperationID:    1,
+		WarningConflictingPathTemplate: 1,
+		WarningUnsupportedParamStyle:   2,
+	}, kinds)
+
+	// The path with the undeclared parameter matches any value
+	_, ok := spec.MatchPath("GET", "/users/foo")
+	assert.True(t, ok)
+
+	// The integer parameter with the label style matches any value
+	_, ok = spec.MatchPath("GET", "/orders/.first")
+	assert.True(t, ok)
+}
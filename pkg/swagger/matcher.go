@@ -0,0 +1,94 @@
+package swagger
+
+import (
+	"sort"
+	"strings"
+)
+
+// API is a specification registered for matching under a name
+type API struct {
+	Name string
+	Spec *Specification
+
+	// Service, when set, binds the API to a kong service, so it only
+	// matches requests routed to that service
+	Service string
+
+	// Prefixes are stripped from request paths before matching, e.g. the
+	// path of a kong route that is not part of the specification
+	Prefixes []string
+
+	// Stale is set when the specification is a last known good copy, used
+	// because loading the current one failed
+	Stale bool
+}
+
+// MatchPath matches the path with the first matching prefix stripped, or the
+// path as is when no prefix matches
+func (a *API) MatchPath(method string, p string) (*Node, bool) {
+	for _, prefix := range a.Prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok {
+			continue
+		}
+
+		// Only strip whole path segments
+		if rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasPrefix(rest, "?") {
+			continue
+		}
+
+		if node, ok := a.Spec.MatchPath(method, rest); ok {
+			return node, true
+		}
+	}
+
+	return a.Spec.MatchPath(method, p)
+}
+
+// Matcher matches requests against the specifications of multiple APIs
+type Matcher struct {
+	APIs []*API
+}
+
+// NewMatcher creates a matcher for the given APIs. APIs are matched in order
+// of their name, so the first API with a matching path wins.
+func NewMatcher(apis []*API) *Matcher {
+	sorted := make([]*API, len(apis))
+	copy(sorted, apis)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return &Matcher{APIs: sorted}
+}
+
+// MatchPath matches a request routed to the given kong service. APIs bound
+// to the service are tried before the APIs not bound to any service.
+func (m *Matcher) MatchPath(service string, method string, p string) (*API, *Node, bool) {
+	if service != "" {
+		for _, api := range m.APIs {
+			if api.Service != service {
+				continue
+			}
+
+			if node, ok := api.MatchPath(method, p); ok {
+				return api, node, true
+			}
+		}
+	}
+
+	for _, api := range m.APIs {
+		if api.Service != "" {
+			continue
+		}
+
+		if node, ok := api.MatchPath(method, p); ok {
+			return api, node, true
+		}
+	}
+
+	return nil, nil, false
+}
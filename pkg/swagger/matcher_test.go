@@ -0,0 +1,63 @@
+package swagger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestMatcher_MatchPath(t *testing.T) {
+	ctx := context.Background()
+
+	simple, err := LoadFile(ctx, "../../testdata/spec.yaml")
+	assert.NoError(t, err)
+
+	multi, err := LoadFile(ctx, "../../testdata/multi/openapi.yaml")
+	assert.NoError(t, err)
+
+	matcher := NewMatcher([]*API{
+		{Name: "simple", Spec: simple},
+		{Name: "multi", Spec: multi},
+		{Name: "bound", Spec: simple, Service: "billing", Prefixes: []string{"/billing/"}},
+	})
+
+	tests := []struct {
+		service string
+		method  string
+		path    string
+		api     string
+		match   bool
+	}{
+		// paths of both APIs match the API first in order of name
+		{"", "GET", "/api/v1/users/1", "multi", true},
+
+		// paths of a single API
+		{"", "POST", "/api/v1/users", "simple", true},
+		{"", "GET", "/api/v1/workers/john-doe/info", "simple", true},
+
+		// paths of no API
+		{"", "GET", "/api/v2/users", "", false},
+
+		// APIs bound to a service are tried first for that service only
+		{"billing", "GET", "/api/v1/users/1", "bound", true},
+		{"users", "GET", "/api/v1/users/1", "multi", true},
+
+		// prefixes are stripped on segment boundaries
+		{"billing", "GET", "/billing/api/v1/users/1", "bound", true},
+		{"billing", "GET", "/billing", "", false},
+		{"billing", "GET", "/billingapi/v1/users/1", "", false},
+		{"", "GET", "/billing/api/v1/users/1", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.service+" "+test.method+" "+test.path, func(t *testing.T) {
+			api, _, ok := matcher.MatchPath(test.service, test.method, test.path)
+			assert.Equal(t, test.match, ok)
+
+			if ok {
+				assert.Equal(t, test.api, api.Name)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package swagger
 
-import v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+import (
+	"context"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+)
 
 var operations = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"}
 
@@ -24,3 +29,42 @@ func isOperationInPathItem(pathItem *v3.PathItem, method string) bool {
 		return false
 	}
 }
+
+func getOperation(pathItem *v3.PathItem, method string) *v3.Operation {
+	switch method {
+	case "GET":
+		return pathItem.Get
+	case "POST":
+		return pathItem.Post
+	case "PUT":
+		return pathItem.Put
+	case "DELETE":
+		return pathItem.Delete
+	case "PATCH":
+		return pathItem.Patch
+	case "OPTIONS":
+		return pathItem.Options
+	case "HEAD":
+		return pathItem.Head
+	default:
+		return nil
+	}
+}
+
+// operationResponses returns the documented response codes of an operation
+func operationResponses(ctx context.Context, operation *v3.Operation) []string {
+	if operation.Responses == nil {
+		return nil
+	}
+
+	var codes []string
+	for code := range orderedmap.Iterate(ctx, operation.Responses.Codes) {
+		codes = append(codes, code.Key())
+	}
+
+	if operation.Responses.Default != nil {
+		codes = append(codes, "default")
+	}
+
+	return codes
+}
@@ -2,45 +2,244 @@ package swagger
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/pb33f/libopenapi"
+	"github.com/pb33f/libopenapi/datamodel"
 )
 
 const minSupportedVersion float32 = 3.0
 
+// ErrNotModified is returned by URLLoader.Load when the specification has not
+// changed since it was last loaded
+var ErrNotModified = errors.New("specification not modified")
+
 func LoadFile(ctx context.Context, path string) (*Specification, error) {
+	return LoadFileWithRefs(ctx, path, DefaultRefOptions)
+}
+
+// LoadFileWithRefs loads a specification from a file, resolving references to
+// other documents as configured
+func LoadFileWithRefs(ctx context.Context, path string, refs RefOptions) (*Specification, error) {
 	specBytes, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return newSpecification(ctx, specBytes)
+	return newSpecification(ctx, specBytes, refs.fileDocumentConfiguration(ctx, path))
 }
 
 func LoadURL(ctx context.Context, url string) (*Specification, error) {
-	httpClient := &http.Client{}
+	return NewURLLoader(url).Load(ctx)
+}
+
+// URLLoader loads a specification from a URL. It remembers the ETag and
+// Last-Modified validators of the last loaded specification, so subsequent
+// loads are conditional and skip the rebuild when nothing changed.
+type URLLoader struct {
+	URL    string
+	Client *http.Client
+
+	// Header is added to every request
+	Header http.Header
+	// Token, when set, is called on every load and sent as a bearer token,
+	// so rotated tokens are picked up without rebuilding the loader
+	Token TokenSource
+	// Username and Password, when set, are sent as basic auth
+	Username string
+	Password string
 
-	resp, err := httpClient.Get(url)
+	// Refs configures how references to other documents are resolved
+	Refs RefOptions
+
+	// Retries is the number of times a failed request is retried, waiting
+	// Backoff before the first retry and doubling the wait for each retry
+	Retries int
+	Backoff time.Duration
+
+	etag         string
+	lastModified string
+}
+
+func NewURLLoader(url string) *URLLoader {
+	return &URLLoader{
+		URL:     url,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+		Retries: 3,
+		Backoff: time.Second,
+		Refs:    DefaultRefOptions,
+	}
+}
+
+func (l *URLLoader) Load(ctx context.Context) (*Specification, error) {
+	resp, err := l.fetch(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	return newSpecification(ctx, body)
+	spec, err := l.Parse(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only remember the validators once the specification is built, so a
+	// broken specification is fetched again on the next load
+	l.etag = resp.Header.Get("ETag")
+	l.lastModified = resp.Header.Get("Last-Modified")
+
+	return spec, nil
+}
+
+// Parse builds a specification from a document of the loader's URL, e.g. a
+// previously fetched copy, resolving its references as if it was fetched
+func (l *URLLoader) Parse(ctx context.Context, specBytes []byte) (*Specification, error) {
+	docConfig, err := l.Refs.urlDocumentConfiguration(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := newRefRecorder()
+	docConfig.RemoteURLHandler = recorder.wrap(docConfig.RemoteURLHandler)
+
+	spec, err := newSpecification(ctx, specBytes, docConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	spec.RefDocuments = recorder.documents
+
+	return spec, nil
+}
+
+// ParseCached builds a specification from a copy of a document of the
+// loader's URL and the documents its references were resolved from, e.g. the
+// RefDocuments of a previously loaded specification. Nothing is fetched, so
+// references to documents that are not given fail.
+func (l *URLLoader) ParseCached(ctx context.Context, specBytes []byte, refDocuments map[string][]byte) (*Specification, error) {
+	docConfig, err := l.Refs.urlDocumentConfiguration(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+
+	docConfig.AllowRemoteReferences = true
+	docConfig.RemoteURLHandler = cachedURLHandler(refDocuments)
+
+	spec, err := newSpecification(ctx, specBytes, docConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	spec.RefDocuments = refDocuments
+
+	return spec, nil
+}
+
+// fetch requests the specification, retrying network errors, rate limits and
+// server errors with exponential backoff
+func (l *URLLoader) fetch(ctx context.Context) (*http.Response, error) {
+	backoff := l.Backoff
+
+	for attempt := 0; ; attempt++ {
+		resp, err := l.request(ctx)
+		if err == nil {
+			switch {
+			case resp.StatusCode == http.StatusNotModified:
+				return resp, nil
+			case resp.StatusCode >= 200 && resp.StatusCode < 300:
+				return resp, nil
+			}
+
+			resp.Body.Close()
+			err = fmt.Errorf("unexpected status %s fetching %s", resp.Status, l.URL)
+
+			retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+			if !retryable {
+				return nil, err
+			}
+		}
+
+		if attempt >= l.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *URLLoader) request(ctx context.Context) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.authorize(req); err != nil {
+		return nil, err
+	}
+
+	if l.etag != "" {
+		req.Header.Set("If-None-Match", l.etag)
+	}
+
+	if l.lastModified != "" {
+		req.Header.Set("If-Modified-Since", l.lastModified)
+	}
+
+	return l.Client.Do(req)
 }
 
-func newSpecification(ctx context.Context, specBytes []byte) (*Specification, error) {
-	document, err := libopenapi.NewDocument(specBytes)
+// authorize adds the configured headers and credentials to the request
+func (l *URLLoader) authorize(req *http.Request) error {
+	for name, values := range l.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if l.Username != "" || l.Password != "" {
+		req.SetBasicAuth(l.Username, l.Password)
+	}
+
+	if l.Token != nil {
+		token, err := l.Token()
+		if err != nil {
+			return fmt.Errorf("failed to get bearer token: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
+
+func newSpecification(
+	ctx context.Context,
+	specBytes []byte,
+	docConfig *datamodel.DocumentConfiguration,
+) (*Specification, error) {
+	resolveRefs(docConfig, specBytes)
+
+	document, err := libopenapi.NewDocumentWithConfiguration(specBytes, docConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -66,5 +265,7 @@ func newSpecification(ctx context.Context, specBytes []byte) (*Specification, er
 		return nil, err
 	}
 
+	spec.Raw = specBytes
+
 	return spec, nil
 }
@@ -0,0 +1,181 @@
+package swagger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestURLLoader_Load(t *testing.T) {
+	ctx := context.Background()
+
+	specBytes, err := os.ReadFile("../../testdata/spec.yaml")
+	assert.NoError(t, err)
+
+	t.Run("conditional requests", func(t *testing.T) {
+		requests := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+
+				return
+			}
+
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(specBytes)
+		}))
+		defer server.Close()
+
+		loader := NewURLLoader(server.URL)
+
+		spec, err := loader.Load(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "Simple OpenAPI 3.0", spec.Meta.Title)
+
+		_, err = loader.Load(ctx)
+		assert.Equal(t, ErrNotModified, err)
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		requests := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		_, err := NewURLLoader(server.URL).Load(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, 1, requests)
+	})
+
+	t.Run("server errors are retried", func(t *testing.T) {
+		requests := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			if requests < 3 {
+				w.WriteHeader(http.StatusInternalServerError)
+
+				return
+			}
+
+			w.Write(specBytes)
+		}))
+		defer server.Close()
+
+		loader := NewURLLoader(server.URL)
+		loader.Backoff = time.Millisecond
+
+		_, err := loader.Load(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, requests)
+
+		loader.Retries = 0
+		requests = 0
+
+		_, err = loader.Load(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, 1, requests)
+	})
+	t.Run("credentials", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Tenant") != "acme" {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			w.Write(specBytes)
+		}))
+		defer server.Close()
+
+		t.Setenv("SPEC_TOKEN", "secret")
+
+		loader := NewURLLoader(server.URL)
+		loader.Header = http.Header{"X-Tenant": {"acme"}}
+		loader.Token = EnvToken("SPEC_TOKEN")
+
+		_, err := loader.Load(ctx)
+		assert.NoError(t, err)
+
+		t.Setenv("SPEC_TOKEN", "rotated")
+
+		_, err = loader.Load(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadFile_ExternalRefs(t *testing.T) {
+	ctx := context.Background()
+
+	spec, err := LoadFile(ctx, "../../testdata/multi/openapi.yaml")
+	assert.NoError(t, err)
+
+	_, ok := spec.MatchPath("GET", "/api/v1/users/1")
+	assert.True(t, ok)
+
+	_, ok = spec.MatchPath("GET", "/api/v1/users/foo")
+	assert.False(t, ok)
+
+	_, err = LoadFileWithRefs(ctx, "../../testdata/multi/openapi.yaml", RefOptions{})
+	assert.Error(t, err)
+}
+
+func TestURLLoader_ExternalRefs(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.FileServer(http.Dir("../../testdata/multi")))
+	defer server.Close()
+
+	spec, err := LoadURL(ctx, server.URL+"/openapi.yaml")
+	assert.NoError(t, err)
+
+	_, ok := spec.MatchPath("GET", "/api/v1/users/1")
+	assert.True(t, ok)
+
+	loader := NewURLLoader(server.URL + "/openapi.yaml")
+	loader.Refs = RefOptions{}
+
+	_, err = loader.Load(ctx)
+	assert.Error(t, err)
+}
+
+func TestURLLoader_ParseCached(t *testing.T) {
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.FileServer(http.Dir("../../testdata/multi")))
+	defer server.Close()
+
+	loader := NewURLLoader(server.URL + "/openapi.yaml")
+
+	spec, err := loader.Load(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, spec.RefDocuments, 2)
+
+	server.Close()
+
+	cached, err := loader.ParseCached(ctx, spec.Raw, spec.RefDocuments)
+	assert.NoError(t, err)
+
+	_, ok := cached.MatchPath("GET", "/api/v1/users/1")
+	assert.True(t, ok)
+
+	_, ok = cached.MatchPath("GET", "/api/v1/users/foo")
+	assert.False(t, ok)
+
+	// References to documents that are not cached are not fetched
+	_, err = loader.ParseCached(ctx, spec.Raw, nil)
+	assert.Error(t, err)
+}
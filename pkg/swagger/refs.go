@@ -0,0 +1,231 @@
+package swagger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pb33f/libopenapi/datamodel"
+	"gopkg.in/yaml.v3"
+)
+
+// RefOptions configures how references to other documents are resolved
+type RefOptions struct {
+	// Relative allows references relative to the specification, resolved
+	// against its directory for files and against its URL for URLs
+	Relative bool
+	// RemoteHosts lists the hosts absolute remote references may point to.
+	// A "*" entry allows any host.
+	RemoteHosts []string
+	// Timeout limits fetching a remote reference of a specification loaded
+	// from a file, 30s when zero. References of specifications loaded from
+	// URLs are fetched with the client of the loader.
+	Timeout time.Duration
+}
+
+// DefaultRefOptions allows relative references and no remote references
+var DefaultRefOptions = RefOptions{
+	Relative: true,
+}
+
+// defaultRefTimeout limits fetching remote references when no timeout is set
+const defaultRefTimeout = 30 * time.Second
+
+func (o RefOptions) hostAllowed(host string) bool {
+	return slices.Contains(o.RemoteHosts, "*") || slices.Contains(o.RemoteHosts, host)
+}
+
+// fileDocumentConfiguration resolves references of a specification loaded
+// from a file
+func (o RefOptions) fileDocumentConfiguration(ctx context.Context, path string) *datamodel.DocumentConfiguration {
+	timeout := o.Timeout
+	if timeout == 0 {
+		timeout = defaultRefTimeout
+	}
+
+	docConfig := datamodel.NewDocumentConfiguration()
+	docConfig.AllowFileReferences = o.Relative
+
+	// libopenapi allows file references whenever a base path is set
+	if o.Relative {
+		docConfig.BasePath = filepath.Dir(path)
+	}
+	docConfig.AllowRemoteReferences = len(o.RemoteHosts) > 0
+	docConfig.RemoteURLHandler = o.remoteURLHandler(ctx, &http.Client{Timeout: timeout}, nil)
+
+	return docConfig
+}
+
+// urlDocumentConfiguration resolves references of a specification loaded by
+// the given loader. Relative references are fetched with the loader's
+// credentials, which are never sent to other hosts.
+func (o RefOptions) urlDocumentConfiguration(ctx context.Context, loader *URLLoader) (*datamodel.DocumentConfiguration, error) {
+	baseURL, err := url.Parse(loader.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve relative references against the directory of the specification
+	baseURL = baseURL.JoinPath("..")
+
+	docConfig := datamodel.NewDocumentConfiguration()
+	docConfig.BaseURL = baseURL
+	docConfig.AllowRemoteReferences = o.Relative || len(o.RemoteHosts) > 0
+	docConfig.RemoteURLHandler = o.remoteURLHandler(ctx, loader.Client, loader)
+
+	return docConfig, nil
+}
+
+// remoteURLHandler fetches remote references, enforcing the allowed hosts
+func (o RefOptions) remoteURLHandler(ctx context.Context, client *http.Client, loader *URLLoader) func(string) (*http.Response, error) {
+	return func(ref string) (*http.Response, error) {
+		refURL, err := url.Parse(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		sameHost := false
+		if loader != nil {
+			if specURL, err := url.Parse(loader.URL); err == nil {
+				sameHost = refURL.Host == specURL.Host
+			}
+		}
+
+		if !(sameHost && o.Relative) && !o.hostAllowed(refURL.Host) {
+			return nil, fmt.Errorf("remote reference to %s is not allowed", ref)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if sameHost {
+			if err := loader.authorize(req); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+
+			return nil, fmt.Errorf("unexpected status %s fetching %s", resp.Status, ref)
+		}
+
+		return resp, nil
+	}
+}
+
+// resolveRefs resolves the references of the specification to other
+// documents one at a time, as libopenapi races when indexing them
+// concurrently. Specifications without such references skip the resolution
+// entirely, keeping the concurrent extraction of their own references.
+func resolveRefs(docConfig *datamodel.DocumentConfiguration, specBytes []byte) {
+	if hasExternalRefs(specBytes) {
+		docConfig.ExtractRefsSequentially = true
+
+		return
+	}
+
+	docConfig.BasePath = ""
+	docConfig.BaseURL = nil
+	docConfig.AllowFileReferences = false
+	docConfig.AllowRemoteReferences = false
+}
+
+// hasExternalRefs reports whether the specification references other
+// documents. Specifications that cannot be parsed are reported as referencing
+// them, leaving the error to libopenapi.
+func hasExternalRefs(specBytes []byte) bool {
+	var root yaml.Node
+	if err := yaml.Unmarshal(specBytes, &root); err != nil {
+		return true
+	}
+
+	return nodeHasExternalRefs(&root)
+}
+
+func nodeHasExternalRefs(node *yaml.Node) bool {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			if key.Value == "$ref" && value.Kind == yaml.ScalarNode && !strings.HasPrefix(value.Value, "#") {
+				return true
+			}
+		}
+	}
+
+	for _, child := range node.Content {
+		if nodeHasExternalRefs(child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refRecorder records the documents fetched to resolve references
+type refRecorder struct {
+	mu        sync.Mutex
+	documents map[string][]byte
+}
+
+func newRefRecorder() *refRecorder {
+	return &refRecorder{documents: map[string][]byte{}}
+}
+
+// wrap records the documents fetched by the handler
+func (r *refRecorder) wrap(handler func(string) (*http.Response, error)) func(string) (*http.Response, error) {
+	return func(ref string) (*http.Response, error) {
+		resp, err := handler(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.documents[ref] = body
+		r.mu.Unlock()
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		return resp, nil
+	}
+}
+
+// cachedURLHandler resolves remote references from previously fetched
+// documents only
+func cachedURLHandler(documents map[string][]byte) func(string) (*http.Response, error) {
+	return func(ref string) (*http.Response, error) {
+		body, ok := documents[ref]
+		if !ok {
+			return nil, fmt.Errorf("referenced document %s is not cached", ref)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	}
+}
@@ -8,6 +8,8 @@ import (
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 )
 
+var wildcardRegex = regexp.MustCompile(`.*`)
+
 func makeRegexFromPath(part string, parameters []*v3.Parameter) (*regexp.Regexp, error) {
 	part = strings.Trim(part, "{}")
 
@@ -26,6 +28,17 @@ func makeRegexFromPath(part string, parameters []*v3.Parameter) (*regexp.Regexp,
 }
 
 func paramToRegex(param *v3.Parameter) *regexp.Regexp {
+	// Only the simple style is supported, other styles prefix or separate
+	// values, e.g. ".5" for the label style, so they can be anything
+	if param.Style != "" && param.Style != "simple" {
+		return wildcardRegex
+	}
+
+	// Parameters described by content instead of a schema can be anything
+	if param.Schema == nil || param.Schema.Schema() == nil {
+		return wildcardRegex
+	}
+
 	types := param.Schema.Schema().Type
 
 	// If the parameter has no type, it can be anything
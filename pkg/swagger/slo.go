@@ -0,0 +1,93 @@
+package swagger
+
+import (
+	"fmt"
+	"time"
+
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// SLOExtension is the operation extension declaring service level objectives,
+// either a single objective or a list of them
+const SLOExtension = "x-slo"
+
+// SLO is a service level objective of an operation
+type SLO struct {
+	Name string
+
+	// Target is the ratio of good requests, e.g. 0.999
+	Target float64
+
+	// Latency, when set, makes requests slower than it bad
+	Latency time.Duration
+}
+
+type sloExtension struct {
+	Name    string  `yaml:"name"`
+	Target  float64 `yaml:"target"`
+	Latency string  `yaml:"latency"`
+}
+
+// operationSLOs returns the objectives declared by the x-slo extension of
+// the operation
+func operationSLOs(operation *v3.Operation) ([]SLO, error) {
+	if operation.Extensions == nil {
+		return nil, nil
+	}
+
+	node := operation.Extensions.GetOrZero(SLOExtension)
+	if node == nil {
+		return nil, nil
+	}
+
+	var extensions []sloExtension
+
+	if node.Kind == yaml.SequenceNode {
+		if err := node.Decode(&extensions); err != nil {
+			return nil, err
+		}
+	} else {
+		var extension sloExtension
+		if err := node.Decode(&extension); err != nil {
+			return nil, err
+		}
+
+		extensions = append(extensions, extension)
+	}
+
+	slos := make([]SLO, 0, len(extensions))
+
+	for _, extension := range extensions {
+		slo := SLO{Name: extension.Name, Target: extension.Target}
+
+		// Targets may be given in percent, e.g. 99.9
+		if slo.Target > 1 {
+			slo.Target /= 100
+		}
+
+		if slo.Target <= 0 || slo.Target >= 1 {
+			return nil, fmt.Errorf("target %v is not between 0 and 100%%", extension.Target)
+		}
+
+		if extension.Latency != "" {
+			latency, err := time.ParseDuration(extension.Latency)
+			if err != nil {
+				return nil, err
+			}
+
+			slo.Latency = latency
+		}
+
+		if slo.Name == "" {
+			slo.Name = "availability"
+			if slo.Latency > 0 {
+				slo.Name = "latency"
+			}
+		}
+
+		slos = append(slos, slo)
+	}
+
+	return slos, nil
+}
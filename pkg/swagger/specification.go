@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/pb33f/libopenapi"
@@ -15,6 +16,16 @@ type Specification struct {
 	Document *libopenapi.DocumentModel[v3.Document]
 	Tree     map[string]*Node
 	Meta     Meta
+
+	// Raw is the document the specification was built from
+	Raw []byte
+
+	// RefDocuments are the remote documents fetched to resolve references of
+	// a specification loaded from a URL, by URL
+	RefDocuments map[string][]byte
+
+	// Warnings are the problems found while building the specification
+	Warnings []Warning
 }
 
 type Meta struct {
@@ -32,6 +43,17 @@ type Node struct {
 	Regex *regexp.Regexp
 
 	Path string
+
+	// OperationID and Tags are taken from the operation of a leaf
+	OperationID string
+	Tags        []string
+
+	// Responses are the response codes documented by the operation of a
+	// leaf, including ranges like "4XX" and "default"
+	Responses []string
+
+	// SLOs are the objectives declared by the operation of a leaf
+	SLOs []SLO
 }
 
 func (n *Node) MatchParam(part string) bool {
@@ -42,6 +64,25 @@ func (n *Node) MatchParam(part string) bool {
 	return n.Regex.MatchString(part)
 }
 
+// DocumentsStatus reports whether the operation of a leaf documents the status
+// code, exactly, by its range or by a default response
+func (n *Node) DocumentsStatus(status int) bool {
+	code := strconv.Itoa(status)
+
+	for _, response := range n.Responses {
+		if response == "default" || response == code {
+			return true
+		}
+
+		// Ranges like "4XX" cover all codes with the same first digit
+		if len(response) == 3 && strings.EqualFold(response[1:], "XX") && response[0] == code[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
 func NewSpecification(ctx context.Context, docModel *libopenapi.DocumentModel[v3.Document]) (*Specification, error) {
 	// Calculate the path prefix based on the server urls
 	basePath := ""
@@ -79,6 +120,8 @@ func NewSpecification(ctx context.Context, docModel *libopenapi.DocumentModel[v3
 		}
 	}
 
+	spec.lint(ctx, docModel)
+
 	return spec, nil
 }
 
@@ -205,10 +248,22 @@ func (s *Specification) buildPathTreeForMethod(
 			if isLastPart {
 				currentNode.Children[part].CanBeLeaf = true
 				currentNode.Children[part].Path = pathItem.Key()
+
+				operation := getOperation(pathItem.Value(), method)
+				currentNode.Children[part].OperationID = operation.OperationId
+				currentNode.Children[part].Tags = operation.Tags
+				currentNode.Children[part].Responses = operationResponses(ctx, operation)
+
+				slos, err := operationSLOs(operation)
+				if err != nil {
+					s.warn(WarningInvalidSLO, method, pathItem.Key(), "invalid %s extension: %s", SLOExtension, err)
+				}
+
+				currentNode.Children[part].SLOs = slos
 			}
 
 			// If this part is a parameter, mark it as such
-			isParam := strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")
+			isParam := isTemplateParam(part)
 			if isParam {
 				currentNode.Children[part].IsParameter = true
 			}
@@ -217,9 +272,12 @@ func (s *Specification) buildPathTreeForMethod(
 			if currentNode.Children[part].IsParameter {
 				params := getPathParametersForOperationStr(method, pathItem.Value())
 
+				// Match anything for parameters that are not declared
 				re, err := makeRegexFromPath(part, params)
 				if err != nil {
-					return err
+					s.warn(WarningUndeclaredPathParameter, method, pathItem.Key(), "%s, matching any value", err)
+
+					re = wildcardRegex
 				}
 
 				currentNode.Children[part].Regex = re
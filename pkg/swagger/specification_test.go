@@ -3,7 +3,9 @@ package swagger
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/pb33f/libopenapi/datamodel"
 	"github.com/tj/assert"
 )
 
@@ -60,3 +62,88 @@ func TestSpecification_MatchPath(t *testing.T) {
 		})
 	}
 }
+
+func TestSpecification_MatchPath_Operation(t *testing.T) {
+	ctx := context.Background()
+
+	spec, err := newSpecification(ctx, []byte(`
+openapi: 3.0.0
+info:
+  title: Reports
+  version: 1.0.0
+paths:
+  /reports:
+    get:
+      operationId: listReports
+      tags: [reports, billing]
+      responses:
+        '200':
+          description: Reports
+        4XX:
+          description: Invalid request
+    post:
+      responses:
+        '201':
+          description: Created a report
+        default:
+          description: Error
+`), datamodel.NewDocumentConfiguration())
+	assert.NoError(t, err)
+
+	node, ok := spec.MatchPath("GET", "/reports")
+	assert.True(t, ok)
+	assert.Equal(t, "listReports", node.OperationID)
+	assert.Equal(t, []string{"reports", "billing"}, node.Tags)
+	assert.True(t, node.DocumentsStatus(200))
+	assert.True(t, node.DocumentsStatus(404))
+	assert.False(t, node.DocumentsStatus(204))
+	assert.False(t, node.DocumentsStatus(500))
+
+	node, ok = spec.MatchPath("POST", "/reports")
+	assert.True(t, ok)
+	assert.Equal(t, "", node.OperationID)
+	assert.Len(t, node.Tags, 0)
+	assert.True(t, node.DocumentsStatus(500))
+}
+
+func TestSpecification_MatchPath_SLOs(t *testing.T) {
+	ctx := context.Background()
+
+	spec, err := newSpecification(ctx, []byte(`
+openapi: 3.0.0
+info:
+  title: Reports
+  version: 1.0.0
+paths:
+  /reports:
+    get:
+      x-slo:
+        target: 99.9
+        latency: 300ms
+      responses:
+        '200':
+          description: Reports
+    post:
+      x-slo:
+        - name: writes
+          target: 0.99
+        - target: 200
+      responses:
+        '201':
+          description: Created a report
+`), datamodel.NewDocumentConfiguration())
+	assert.NoError(t, err)
+
+	node, ok := spec.MatchPath("GET", "/reports")
+	assert.True(t, ok)
+	assert.Len(t, node.SLOs, 1)
+	assert.Equal(t, "latency", node.SLOs[0].Name)
+	assert.InDelta(t, 0.999, node.SLOs[0].Target, 1e-9)
+	assert.Equal(t, 300*time.Millisecond, node.SLOs[0].Latency)
+
+	node, ok = spec.MatchPath("POST", "/reports")
+	assert.True(t, ok)
+	assert.Len(t, node.SLOs, 0)
+	assert.Len(t, spec.Warnings, 1)
+	assert.Equal(t, WarningInvalidSLO, spec.Warnings[0].Kind)
+}
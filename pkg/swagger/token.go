@@ -0,0 +1,34 @@
+package swagger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TokenSource returns the bearer token used to fetch a specification
+type TokenSource func() (string, error)
+
+// FileToken reads the token from a file on every call
+func FileToken(path string) TokenSource {
+	return func() (string, error) {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(token)), nil
+	}
+}
+
+// EnvToken reads the token from an environment variable on every call
+func EnvToken(name string) TokenSource {
+	return func() (string, error) {
+		token, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return strings.TrimSpace(token), nil
+	}
+}